@@ -0,0 +1,333 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ByChanderZap/snippetbox/internal/models"
+	"github.com/ByChanderZap/snippetbox/internal/validator"
+)
+
+// snippetJSON is how snippets look in the api, it keeps the password hash and other internals
+// out of the response and spells the flags out for clients
+type snippetJSON struct {
+	ID                int        `json:"id"`
+	Slug              string     `json:"slug"`
+	URL               string     `json:"url"`
+	Title             string     `json:"title"`
+	Content           string     `json:"content"`
+	Language          string     `json:"language"`
+	Visibility        string     `json:"visibility"`
+	Tags              []string   `json:"tags"`
+	Author            string     `json:"author"`
+	Created           time.Time  `json:"created"`
+	Expires           *time.Time `json:"expires"`
+	BurnAfterReading  bool       `json:"burn_after_reading"`
+	PasswordProtected bool       `json:"password_protected"`
+	Encrypted         bool       `json:"encrypted"`
+}
+
+func newSnippetJSON(r *http.Request, s models.Snippet) snippetJSON {
+	tags := s.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	return snippetJSON{
+		ID:                s.ID,
+		Slug:              s.Slug,
+		URL:               absoluteURL(r, "/s/"+s.Slug),
+		Title:             s.Title,
+		Content:           s.Content,
+		Language:          s.Language,
+		Visibility:        s.Visibility,
+		Tags:              tags,
+		Author:            s.UserName,
+		Created:           s.Created,
+		Expires:           s.Expires,
+		BurnAfterReading:  s.ViewsLeft > 0,
+		PasswordProtected: s.HashedPassword != nil,
+		Encrypted:         s.Encrypted,
+	}
+}
+
+type snippetListJSON struct {
+	Snippets   []snippetJSON `json:"snippets"`
+	NextCursor int           `json:"next_cursor,omitempty"`
+	PrevCursor int           `json:"prev_cursor,omitempty"`
+}
+
+// newSnippetListJSON lists page as seen by userID, the content of password protected and burn
+// after reading snippets is left blank for anyone but their author since only the unlock and
+// reveal pages may hand it out, the same rule apiSnippet applies to a single snippet
+func newSnippetListJSON(r *http.Request, page models.SnippetPage, userID int) snippetListJSON {
+	list := snippetListJSON{
+		Snippets:   make([]snippetJSON, 0, len(page.Snippets)),
+		NextCursor: page.NextCursor,
+		PrevCursor: page.PrevCursor,
+	}
+	for _, s := range page.Snippets {
+		js := newSnippetJSON(r, s)
+		if s.UserID != userID && (s.ViewsLeft > 0 || s.HashedPassword != nil) {
+			js.Content = ""
+		}
+		list.Snippets = append(list.Snippets, js)
+	}
+	return list
+}
+
+// snippetRequest is the body of create and update, it mirrors snippetCreateForm so both
+// go through the same validation
+type snippetRequest struct {
+	Title          string   `json:"title"`
+	Content        string   `json:"content"`
+	Expires        string   `json:"expires"`
+	ExpiresAmount  int      `json:"expires_amount"`
+	ExpiresUnit    string   `json:"expires_unit"`
+	ExpiresAt      string   `json:"expires_at"`
+	Tags           []string `json:"tags"`
+	Language       string   `json:"language"`
+	Visibility     string   `json:"visibility"`
+	Burn           bool     `json:"burn"`
+	Password       string   `json:"password"`
+	RemovePassword bool     `json:"remove_password"`
+}
+
+// form fills in the defaults a script would not bother sending: plain text, public and never expiring
+func (req snippetRequest) form() snippetCreateForm {
+	form := snippetCreateForm{
+		Title:          req.Title,
+		Content:        req.Content,
+		Expires:        req.Expires,
+		ExpiresAmount:  req.ExpiresAmount,
+		ExpiresUnit:    req.ExpiresUnit,
+		ExpiresAt:      req.ExpiresAt,
+		Tags:           strings.Join(req.Tags, ","),
+		Language:       req.Language,
+		Visibility:     req.Visibility,
+		Burn:           req.Burn,
+		Password:       req.Password,
+		RemovePassword: req.RemovePassword,
+	}
+
+	if form.Expires == "" {
+		form.Expires = "never"
+	}
+	if form.Language == "" {
+		form.Language = "text"
+	}
+	if form.Visibility == "" {
+		form.Visibility = models.VisibilityPublic
+	}
+
+	return form
+}
+
+type apiErrorJSON struct {
+	Error string `json:"error"`
+}
+
+type apiValidationErrorJSON struct {
+	Errors map[string]string `json:"errors"`
+}
+
+// apiError is the json counterpart of clientError
+func (app *application) apiError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	app.writeJSON(w, r, status, apiErrorJSON{Error: message})
+}
+
+// apiValidationError serialises the field errors of a failed form as is
+func (app *application) apiValidationError(w http.ResponseWriter, r *http.Request, fieldErrors map[string]string) {
+	app.writeJSON(w, r, http.StatusUnprocessableEntity, apiValidationErrorJSON{Errors: fieldErrors})
+}
+
+// apiSnippet loads the snippet of the path for api handlers, the rules are the ones of the html
+// view except that the pages asking for a password or a confirmation have no json equivalent
+func (app *application) apiSnippet(w http.ResponseWriter, r *http.Request) (models.Snippet, bool) {
+	s, err := app.lookupSnippet(r)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiError(w, r, http.StatusNotFound, "snippet not found")
+			return models.Snippet{}, false
+		}
+		app.serverError(w, r, err)
+		return models.Snippet{}, false
+	}
+
+	isOwner := s.UserID == app.authenticatedUserID(r)
+	if !isOwner && s.ViewsLeft > 0 {
+		app.apiError(w, r, http.StatusForbidden, "this snippet burns after reading, open it in a browser")
+		return models.Snippet{}, false
+	}
+	if !isOwner && s.HashedPassword != nil {
+		app.apiError(w, r, http.StatusForbidden, "this snippet is password protected, open it in a browser")
+		return models.Snippet{}, false
+	}
+
+	return s, true
+}
+
+// apiOwnedSnippet is apiSnippet for the handlers that change a snippet
+func (app *application) apiOwnedSnippet(w http.ResponseWriter, r *http.Request) (models.Snippet, bool) {
+	s, ok := app.apiSnippet(w, r)
+	if !ok {
+		return models.Snippet{}, false
+	}
+
+	if s.UserID != app.authenticatedUserID(r) {
+		app.apiError(w, r, http.StatusForbidden, "only the author can change this snippet")
+		return models.Snippet{}, false
+	}
+
+	return s, true
+}
+
+// apiMaxLimit is the most snippets a client can ask for in one page
+const apiMaxLimit = 100
+
+// apiSnippetList lists public snippets, "mine=true" lists every snippet of the token owner instead
+func (app *application) apiSnippetList(w http.ResponseWriter, r *http.Request) {
+	params, _, err := readPageParams(r)
+	if err != nil {
+		app.apiError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	qs := r.URL.Query()
+	if v := qs.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > apiMaxLimit {
+			app.apiError(w, r, http.StatusBadRequest, "limit must be between 1 and 100")
+			return
+		}
+		params.Limit = limit
+	}
+	if tag := strings.ToLower(qs.Get("tag")); tag != "" {
+		if !validator.TagRX.MatchString(tag) {
+			app.apiError(w, r, http.StatusBadRequest, "tag can only contain lowercase letters, numbers and + # . _ -, up to 30 characters")
+			return
+		}
+		params.Tag = tag
+	}
+
+	if qs.Get("mine") == "true" {
+		if !app.isAuthenticated(r) {
+			app.tokenRequired(w, r)
+			return
+		}
+		params.UserID = app.authenticatedUserID(r)
+	}
+
+	page, err := app.snippets.Page(r.Context(), params)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, r, http.StatusOK, newSnippetListJSON(r, page, app.authenticatedUserID(r)))
+}
+
+func (app *application) apiSnippetGet(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.apiSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	app.writeJSON(w, r, http.StatusOK, newSnippetJSON(r, s))
+}
+
+func (app *application) apiSnippetCreate(w http.ResponseWriter, r *http.Request) {
+	var req snippetRequest
+	err := app.readJSON(w, r, &req)
+	if err != nil {
+		app.apiError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	form := req.form()
+	form.validate()
+	if !form.Valid() {
+		app.apiValidationError(w, r, form.FieldErrors)
+		return
+	}
+
+	slug, err := app.insertSnippet(r.Context(), &form, app.authenticatedUserID(r), false)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	s, err := app.snippets.GetBySlug(r.Context(), slug)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", "/api/v1/snippets/"+slug)
+	app.writeJSON(w, r, http.StatusCreated, newSnippetJSON(r, s))
+}
+
+// apiSnippetUpdate replaces the snippet with the body, fields left out get the same defaults as
+// on create except the password which is only dropped with remove_password
+func (app *application) apiSnippetUpdate(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.apiOwnedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	if s.Encrypted {
+		app.apiError(w, r, http.StatusConflict, "encrypted snippets can't be edited, delete it and create a new one")
+		return
+	}
+
+	var req snippetRequest
+	err := app.readJSON(w, r, &req)
+	if err != nil {
+		app.apiError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	form := req.form()
+	form.CurrentExpiry = s.Expires
+	form.validate()
+	if !form.Valid() {
+		app.apiValidationError(w, r, form.FieldErrors)
+		return
+	}
+
+	err = app.updateSnippet(r.Context(), s, &form)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	s, err = app.snippets.Get(r.Context(), s.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.writeJSON(w, r, http.StatusOK, newSnippetJSON(r, s))
+}
+
+func (app *application) apiSnippetDelete(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.apiOwnedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.snippets.Delete(r.Context(), models.DeleteSnippetParams{ID: s.ID, UserID: s.UserID})
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiError(w, r, http.StatusNotFound, "snippet not found")
+			return
+		}
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
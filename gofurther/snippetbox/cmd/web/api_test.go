@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ByChanderZap/snippetbox/internal/assert"
+	"github.com/ByChanderZap/snippetbox/internal/models"
+)
+
+func TestSnippetRequestForm(t *testing.T) {
+	form := snippetRequest{
+		Title:   "Hello",
+		Content: "fmt.Println(1)",
+		Tags:    []string{"go", "cli"},
+	}.form()
+
+	assert.Equal(t, "never", form.Expires)
+	assert.Equal(t, "text", form.Language)
+	assert.Equal(t, models.VisibilityPublic, form.Visibility)
+	assert.Equal(t, "go,cli", form.Tags)
+
+	form.validate()
+	assert.Equal(t, true, form.Valid())
+
+	form = snippetRequest{Language: "cobol", Tags: []string{"Not A Tag"}}.form()
+	form.validate()
+	assert.Equal(t, false, form.Valid())
+	for _, field := range []string{"title", "content", "language", "tags"} {
+		_, ok := form.FieldErrors[field]
+		assert.Equal(t, true, ok)
+	}
+}
+
+func TestReadJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr bool
+	}{
+		{name: "Valid", body: `{"title": "Hello"}`},
+		{name: "Unknown field", body: `{"titel": "Hello"}`, wantErr: true},
+		{name: "Two values", body: `{"title": "a"} {"title": "b"}`, wantErr: true},
+		{name: "Malformed", body: `{"title": `, wantErr: true},
+	}
+
+	app := &application{}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodPost, "/api/v1/snippets", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var dst snippetRequest
+			err = app.readJSON(rr, req, &dst)
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
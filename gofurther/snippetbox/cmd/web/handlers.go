@@ -1,24 +1,168 @@
 package main
 
 import (
+	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"io"
+	"maps"
+	"mime"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/ByChanderZap/snippetbox/internal/diff"
 	"github.com/ByChanderZap/snippetbox/internal/models"
 	"github.com/ByChanderZap/snippetbox/internal/validator"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type snippetCreateForm struct {
-	Title   string `form:"title"`
-	Content string `form:"content"`
-	Expires int    `form:"expires"`
+	Title      string `form:"title"`
+	Content    string `form:"content"`
+	Expires    string `form:"expires"`
+	Tags       string `form:"tags"`
+	Language   string `form:"language"`
+	Visibility string `form:"visibility"`
+	Burn       bool   `form:"burn"`
+	// ExpiresAmount and ExpiresUnit are read when Expires is "custom", ExpiresAt when it is "date"
+	ExpiresAmount int    `form:"expires_amount"`
+	ExpiresUnit   string `form:"expires_unit"`
+	ExpiresAt     string `form:"expires_at"`
+	// CurrentExpiry is the expiry of the snippet being edited, "keep" leaves it untouched
+	CurrentExpiry *time.Time `form:"-"`
+	// Password is optional, on edit leaving it blank keeps the current one
+	Password       string `form:"password"`
+	RemovePassword bool   `form:"remove_password"`
 	// This "-" tells the decoder to ignore this field
 	validator.Validator `form:"-"`
 }
 
+// expiryPresets are the quick picks of the expiry radio, besides these the form accepts
+// "never", "custom" (an amount of hours or days), "date" (an explicit UTC date-time) and
+// "keep" when editing a snippet that already expires
+var expiryPresets = map[string]time.Duration{
+	"1h":   time.Hour,
+	"1d":   24 * time.Hour,
+	"7d":   7 * 24 * time.Hour,
+	"30d":  30 * 24 * time.Hour,
+	"365d": 365 * 24 * time.Hour,
+}
+
+var expiryUnits = map[string]time.Duration{
+	"hours": time.Hour,
+	"days":  24 * time.Hour,
+}
+
+// maxExpiry caps custom durations and dates, anything longer should just be "never"
+const maxExpiry = 10 * 365 * 24 * time.Hour
+
+// datetime-local inputs submit this layout, we always read it as UTC
+const expiresAtLayout = "2006-01-02T15:04"
+
+// expiresAt turns the expiry fields into the time the snippet stops being served, nil means never.
+// it assumes validate already accepted the form
+func (form *snippetCreateForm) expiresAt(now time.Time) *time.Time {
+	var t time.Time
+
+	switch form.Expires {
+	case "never":
+		return nil
+	case "keep":
+		return form.CurrentExpiry
+	case "custom":
+		t = now.Add(time.Duration(form.ExpiresAmount) * expiryUnits[form.ExpiresUnit])
+	case "date":
+		if form.unchangedDate() {
+			return form.CurrentExpiry
+		}
+		t, _ = time.ParseInLocation(expiresAtLayout, form.ExpiresAt, time.UTC)
+	default:
+		t = now.Add(expiryPresets[form.Expires])
+	}
+
+	t = t.UTC()
+	return &t
+}
+
+// unchangedDate is true when the date field still holds the current expiry as the edit form
+// filled it in, that date may have passed the minute it was cut to so it isn't checked again
+func (form *snippetCreateForm) unchangedDate() bool {
+	return form.CurrentExpiry != nil && form.ExpiresAt == form.CurrentExpiry.UTC().Format(expiresAtLayout)
+}
+
+func (form *snippetCreateForm) validateExpiry(now time.Time) {
+	switch form.Expires {
+	case "never":
+	case "keep":
+		form.CheckField(form.CurrentExpiry != nil, "expires", "Please pick one of the listed expiry options")
+	case "custom":
+		form.CheckField(validator.PermittedValues(form.ExpiresUnit, "hours", "days"), "expires", "Pick hours or days")
+		if d, ok := expiryUnits[form.ExpiresUnit]; ok {
+			total := time.Duration(form.ExpiresAmount) * d
+			form.CheckField(form.ExpiresAmount > 0 && total <= maxExpiry, "expires", "The amount must be positive and at most 10 years")
+		}
+	case "date":
+		if form.unchangedDate() {
+			return
+		}
+		t, err := time.ParseInLocation(expiresAtLayout, form.ExpiresAt, time.UTC)
+		form.CheckField(err == nil, "expires", "This is not a valid date and time")
+		if err == nil {
+			form.CheckField(t.After(now) && t.Sub(now) <= maxExpiry, "expires", "The date must be in the future and at most 10 years away")
+		}
+	default:
+		_, ok := expiryPresets[form.Expires]
+		form.CheckField(ok, "expires", "Please pick one of the listed expiry options")
+	}
+}
+
+// maxContentSize is the most content a snippet can hold in bytes, whichever way it is created.
+// It fits the MEDIUMTEXT column of MySQL, the smallest of the backends
+const maxContentSize = 1 << 20
+
+// validate is shared by the create and edit flows so both enforce the same rules
+func (form *snippetCreateForm) validate() {
+	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
+	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
+	form.CheckField(len(form.Content) <= maxContentSize, "content", "This field cannot be more than 1 MiB")
+	form.validateExpiry(time.Now())
+	form.CheckField(validator.PermittedValues(form.Language, languageValues()...), "language", "Please pick one of the listed languages")
+	form.CheckField(validator.PermittedValues(form.Visibility, models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate), "visibility", "This field must be one of public, unlisted, private")
+
+	if form.Password != "" {
+		form.CheckField(validator.MinChars(form.Password, 8), "password", "password must be at least 8 characters long")
+	}
+
+	tags := parseTags(form.Tags)
+	form.CheckField(validator.MaxItems(tags, 10), "tags", "A snippet can have at most 10 tags")
+	form.CheckField(validator.AllMatch(tags, validator.TagRX), "tags", "Tags can only contain lowercase letters, numbers and + # . _ -, up to 30 characters")
+}
+
+// maxViews turns the burn after reading checkbox into the view limit stored with the snippet
+func (form *snippetCreateForm) maxViews() int {
+	if form.Burn {
+		return 1
+	}
+	return 0
+}
+
+type snippetUnlockForm struct {
+	Password string `form:"password"`
+
+	validator.Validator `form:"-"`
+}
+
+type tokenCreateForm struct {
+	Name string `form:"name"`
+
+	validator.Validator `form:"-"`
+}
+
 type userSignupForm struct {
 	Name     string `form:"name"`
 	Email    string `form:"email"`
@@ -39,25 +183,123 @@ func ping(w http.ResponseWriter, r *http.Request) {
 }
 
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
-	snippets, err := app.snippets.Latest()
+	params, pageNum, err := readPageParams(r)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	page, err := app.snippets.Page(r.Context(), params)
 	if err != nil {
 		app.serverError(w, r, err)
+		return
 	}
 
 	data := app.newTemplateData(r)
-	data.Snippets = snippets
+	data.Snippets = page.Snippets
+	data.Pagination = newPagination(r, page, pageNum)
 
-	app.render(w, r, http.StatusOK, "home.tmpl", data)
+	app.respond(w, r, http.StatusOK, "home.tmpl", data, newSnippetListJSON(r, page, app.authenticatedUserID(r)))
 }
 
 func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
-	id, err := strconv.Atoi(r.PathValue("id"))
-	if err != nil || id < 1 {
-		http.NotFound(w, r)
+	s, ok := app.snippetFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	// the integer urls are kept working for old links but the slug one is canonical
+	if r.PathValue("slug") == "" {
+		http.Redirect(w, r, "/s/"+s.Slug, http.StatusMovedPermanently)
 		return
 	}
 
-	s, err := app.snippets.Get(id)
+	if app.passwordLocked(r, s) {
+		// the unlock page is a form, json clients just get told why they can't read it
+		if wantsJSON(w, r) {
+			app.apiError(w, r, http.StatusForbidden, "this snippet is password protected, open it in a browser")
+			return
+		}
+
+		data := app.newTemplateData(r)
+		data.Snippet = models.Snippet{Slug: s.Slug, Title: s.Title}
+		data.Form = snippetUnlockForm{}
+		app.render(w, r, http.StatusOK, "unlock.tmpl", data)
+		return
+	}
+
+	// view limited snippets are only consumed through the POST on the confirmation page,
+	// otherwise chat apps fetching link previews would burn them before anyone reads them
+	if s.ViewsLeft > 0 && s.UserID != app.authenticatedUserID(r) {
+		if wantsJSON(w, r) {
+			app.apiError(w, r, http.StatusForbidden, "this snippet burns after reading, open it in a browser")
+			return
+		}
+
+		data := app.newTemplateData(r)
+		data.Snippet = models.Snippet{Slug: s.Slug, Title: s.Title, ViewsLeft: s.ViewsLeft}
+		app.render(w, r, http.StatusOK, "reveal.tmpl", data)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = s
+
+	app.respond(w, r, http.StatusOK, "view.tmpl", data, newSnippetJSON(r, s))
+}
+
+func (app *application) snippetUnlockPost(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var form snippetUnlockForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Password), "password", "password cannot be empty")
+
+	if form.Valid() {
+		err = app.snippets.Unlock(r.Context(), models.UnlockSnippetParams{Slug: s.Slug, Password: form.Password})
+		if err != nil {
+			if !errors.Is(err, models.ErrInvalidCredentials) {
+				app.serverError(w, r, err)
+				return
+			}
+			form.AddNonFieldError("Wrong password")
+		}
+	}
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Snippet = models.Snippet{Slug: s.Slug, Title: s.Title}
+		data.Form = form
+		app.render(w, r, http.StatusBadRequest, "unlock.tmpl", data)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), unlockedSessionKey(s.Slug), unlockVersion(s))
+
+	http.Redirect(w, r, "/s/"+s.Slug, http.StatusSeeOther)
+}
+
+func (app *application) snippetRevealPost(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	// the author looking at their own snippet never uses up a view
+	if s.ViewsLeft == 0 || s.UserID == app.authenticatedUserID(r) || app.passwordLocked(r, s) {
+		http.Redirect(w, r, "/s/"+s.Slug, http.StatusSeeOther)
+		return
+	}
+
+	s, err := app.snippets.Reveal(r.Context(), models.RevealParams{Slug: s.Slug})
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
 			http.NotFound(w, r)
@@ -69,21 +311,209 @@ func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 
 	data := app.newTemplateData(r)
 	data.Snippet = s
+	data.Burned = s.ViewsLeft == 0
 
 	app.render(w, r, http.StatusOK, "view.tmpl", data)
 }
 
+func (app *application) snippetRaw(w http.ResponseWriter, r *http.Request) {
+	app.serveSnippetContent(w, r, false)
+}
+
+func (app *application) snippetDownload(w http.ResponseWriter, r *http.Request) {
+	app.serveSnippetContent(w, r, true)
+}
+
+// serveSnippetContent writes the bare content as plain text, the same visibility rules as the
+// html view apply and content that needs the confirmation or unlock pages is never served here
+func (app *application) serveSnippetContent(w http.ResponseWriter, r *http.Request, attachment bool) {
+	s, ok := app.snippetFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if s.ViewsLeft > 0 && s.UserID != app.authenticatedUserID(r) {
+		http.NotFound(w, r)
+		return
+	}
+
+	if app.passwordLocked(r, s) {
+		app.clientError(w, r, http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if attachment {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+			"filename": downloadFilename(s),
+		}))
+	}
+
+	w.Write([]byte(s.Content))
+}
+
+func (app *application) snippetHistory(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if s.ViewsLeft > 0 && s.UserID != app.authenticatedUserID(r) {
+		http.NotFound(w, r)
+		return
+	}
+
+	if app.passwordLocked(r, s) {
+		http.Redirect(w, r, "/s/"+s.Slug, http.StatusSeeOther)
+		return
+	}
+
+	revisions, err := app.snippets.Revisions(r.Context(), models.RevisionsParams{SnippetID: s.ID})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = s
+	data.Revisions = revisions
+
+	app.render(w, r, http.StatusOK, "history.tmpl", data)
+}
+
+// snippetVersion returns the revision with the given id, 0 stands for the live snippet
+func (app *application) snippetVersion(ctx context.Context, s models.Snippet, revisionID int) (models.Revision, error) {
+	if revisionID == 0 {
+		return models.Revision{
+			SnippetID: s.ID,
+			Title:     s.Title,
+			Content:   s.Content,
+			Created:   s.Created,
+		}, nil
+	}
+
+	return app.snippets.Revision(ctx, models.GetRevisionParams{ID: revisionID, SnippetID: s.ID})
+}
+
+func (app *application) snippetDiff(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if s.ViewsLeft > 0 && s.UserID != app.authenticatedUserID(r) {
+		http.NotFound(w, r)
+		return
+	}
+
+	if app.passwordLocked(r, s) {
+		http.Redirect(w, r, "/s/"+s.Slug, http.StatusSeeOther)
+		return
+	}
+
+	fromID, err := strconv.Atoi(r.URL.Query().Get("from"))
+	if err != nil || fromID < 0 {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	// comparing against the live snippet is the common case so "to" is optional
+	toID := 0
+	if to := r.URL.Query().Get("to"); to != "" {
+		toID, err = strconv.Atoi(to)
+		if err != nil || toID < 0 {
+			app.clientError(w, r, http.StatusBadRequest)
+			return
+		}
+	}
+
+	from, err := app.snippetVersion(r.Context(), s, fromID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+			return
+		}
+		app.serverError(w, r, err)
+		return
+	}
+
+	to, err := app.snippetVersion(r.Context(), s, toID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+			return
+		}
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = s
+	data.Diff = snippetDiff{
+		From:  from,
+		To:    to,
+		Lines: diff.Lines(from.Content, to.Content),
+	}
+
+	app.render(w, r, http.StatusOK, "diff.tmpl", data)
+}
+
 func (app *application) snippetCreateForm(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 
 	data.Form = snippetCreateForm{
-		Expires: 365,
+		Expires:    "365d",
+		Language:   "text",
+		Visibility: models.VisibilityPublic,
 	}
 
 	app.render(w, r, http.StatusOK, "create.tmpl", data)
 }
 
 func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request) {
+	app.createSnippet(w, r, false)
+}
+
+func (app *application) snippetCreateEncryptedForm(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+
+	data.Form = snippetCreateForm{
+		Expires:    "365d",
+		Visibility: models.VisibilityUnlisted,
+	}
+
+	app.render(w, r, http.StatusOK, "create_encrypted.tmpl", data)
+}
+
+func (app *application) snippetCreateEncryptedPost(w http.ResponseWriter, r *http.Request) {
+	app.createSnippet(w, r, true)
+}
+
+// envelopePrefix starts the content of encrypted snippets, a new scheme in ui/static/js/encrypted.js
+// gets a new version so the snippets stored before it still decrypt
+const envelopePrefix = "v1:"
+
+// AES-GCM sizes, the tag is appended to the ciphertext by WebCrypto
+const (
+	gcmIVSize  = 12
+	gcmTagSize = 16
+)
+
+// validEnvelope checks the content is what the browser sends: the version prefix then
+// base64(iv || ciphertext || tag) with at least one byte of ciphertext
+func validEnvelope(content string) bool {
+	payload, ok := strings.CutPrefix(content, envelopePrefix)
+	if !ok {
+		return false
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(payload)
+	return err == nil && len(raw) > gcmIVSize+gcmTagSize
+}
+
+// createSnippet handles both create forms, for encrypted snippets the browser already replaced
+// the content with base64 ciphertext so there is nothing to highlight or tag
+func (app *application) createSnippet(w http.ResponseWriter, r *http.Request, encrypted bool) {
 	var form snippetCreateForm
 	err := app.decodePostForm(r, &form)
 	if err != nil {
@@ -91,32 +521,440 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
-	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be blank")
-	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
-	form.CheckField(validator.PermittedValues(form.Expires, 1, 7, 365), "expires", "This field must be one of 1, 7, 365")
+	page := "create.tmpl"
+	if encrypted {
+		page = "create_encrypted.tmpl"
+		form.Language = "text"
+		form.Tags = ""
+		form.Password = ""
+	}
+
+	form.validate()
+	if encrypted {
+		form.CheckField(validEnvelope(form.Content), "content", "The content was not encrypted, make sure JavaScript is enabled")
+	}
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusBadRequest, page, data)
+		return
+	}
+
+	slug, err := app.insertSnippet(r.Context(), &form, app.authenticatedUserID(r), encrypted)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully created")
+
+	http.Redirect(w, r, "/s/"+slug, http.StatusSeeOther)
+}
+
+// insertSnippet stores an already validated form together with its tags and returns the new slug
+func (app *application) insertSnippet(ctx context.Context, form *snippetCreateForm, userID int, encrypted bool) (string, error) {
+	var hashedPassword []byte
+	if form.Password != "" {
+		var err error
+		hashedPassword, err = bcrypt.GenerateFromPassword([]byte(form.Password), 12)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	_, slug, err := app.snippets.Insert(ctx, models.InsertSnippetParams{
+		Title:          form.Title,
+		Content:        form.Content,
+		Expires:        form.expiresAt(time.Now()),
+		MaxViews:       form.maxViews(),
+		UserID:         userID,
+		Language:       form.Language,
+		Visibility:     form.Visibility,
+		HashedPassword: hashedPassword,
+		Encrypted:      encrypted,
+		Tags:           parseTags(form.Tags),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return slug, nil
+}
+
+// maxPasteSize caps what /paste reads, on top of maxContentSize it leaves room for the multipart envelope
+const maxPasteSize = maxContentSize + 64<<10
+
+// pastePost lets terminal users create snippets with something like `cmd | curl --data-binary @- host/paste`.
+// the body is the content as is, or a multipart "file" field, the rest of the create form fields can be
+// sent as query parameters (or multipart fields) and fall back to an unlisted plain text snippet that lasts a week
+func (app *application) pastePost(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxPasteSize)
+
+	form := snippetCreateForm{
+		Title:      "Untitled paste",
+		Expires:    "7d",
+		Language:   "text",
+		Visibility: models.VisibilityUnlisted,
+	}
+
+	fields := r.URL.Query()
+	var content []byte
+	var err error
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		content, err = app.readPasteFile(r, &form)
+		fields = r.Form
+	} else {
+		content, err = io.ReadAll(r.Body)
+	}
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			app.clientError(w, r, http.StatusRequestEntityTooLarge)
+			return
+		}
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	err = app.formDecoder.Decode(&form, fields)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form.Content = string(content)
+	form.validate()
+	if !form.Valid() {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusBadRequest)
+		// sorted so the same bad request always gets the same answer
+		for _, field := range slices.Sorted(maps.Keys(form.FieldErrors)) {
+			fmt.Fprintf(w, "%s: %s\n", field, form.FieldErrors[field])
+		}
+		return
+	}
+
+	slug, err := app.insertSnippet(r.Context(), &form, app.authenticatedUserID(r), false)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintln(w, absoluteURL(r, "/s/"+slug))
+}
+
+// readPasteFile reads the "file" part of a multipart paste, its name is used as the default title and
+// to guess the language, explicit fields still win since they are decoded afterwards
+func (app *application) readPasteFile(r *http.Request, form *snippetCreateForm) ([]byte, error) {
+	err := r.ParseMultipartForm(maxPasteSize)
+	if err != nil {
+		return nil, err
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if header.Filename != "" {
+		form.Title = header.Filename
+		form.Language = languageForFilename(header.Filename)
+	}
+
+	return io.ReadAll(file)
+}
+
+func (app *application) snippetEditForm(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	if s.Encrypted {
+		app.encryptedNotEditable(w, r, s)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = s
+	form := snippetCreateForm{
+		Title:      s.Title,
+		Content:    s.Content,
+		Expires:    "never",
+		Tags:       strings.Join(s.Tags, ", "),
+		Language:   s.Language,
+		Visibility: s.Visibility,
+		Burn:       s.ViewsLeft > 0,
+	}
+	if s.Expires != nil {
+		form.Expires = "keep"
+		form.ExpiresAt = s.Expires.UTC().Format(expiresAtLayout)
+		form.CurrentExpiry = s.Expires
+	}
+	data.Form = form
+
+	app.render(w, r, http.StatusOK, "edit.tmpl", data)
+}
+
+func (app *application) snippetEditPost(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	if s.Encrypted {
+		app.encryptedNotEditable(w, r, s)
+		return
+	}
+
+	var form snippetCreateForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form.CurrentExpiry = s.Expires
+	form.validate()
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Snippet = s
+		data.Form = form
+		app.render(w, r, http.StatusBadRequest, "edit.tmpl", data)
+		return
+	}
+
+	err = app.updateSnippet(r.Context(), s, &form)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully updated")
+
+	http.Redirect(w, r, "/s/"+s.Slug, http.StatusSeeOther)
+}
+
+// updateSnippet stores an already validated form over s, the password is kept unless
+// the form replaces or removes it
+func (app *application) updateSnippet(ctx context.Context, s models.Snippet, form *snippetCreateForm) error {
+	hashedPassword := s.HashedPassword
+	switch {
+	case form.RemovePassword:
+		hashedPassword = nil
+	case form.Password != "":
+		var err error
+		hashedPassword, err = bcrypt.GenerateFromPassword([]byte(form.Password), 12)
+		if err != nil {
+			return err
+		}
+	}
+
+	return app.snippets.Update(ctx, models.UpdateSnippetParams{
+		ID:             s.ID,
+		UserID:         s.UserID,
+		Title:          form.Title,
+		Content:        form.Content,
+		Expires:        form.expiresAt(time.Now()),
+		MaxViews:       form.maxViews(),
+		Language:       form.Language,
+		Visibility:     form.Visibility,
+		HashedPassword: hashedPassword,
+		Tags:           parseTags(form.Tags),
+	})
+}
+
+// encryptedNotEditable sends the owner back to the snippet, we only have ciphertext so the
+// edit form would just show them garbage
+func (app *application) encryptedNotEditable(w http.ResponseWriter, r *http.Request, s models.Snippet) {
+	app.sessionManager.Put(r.Context(), "flash", "Encrypted snippets can't be edited, delete it and create a new one")
+	http.Redirect(w, r, "/s/"+s.Slug, http.StatusSeeOther)
+}
+
+func (app *application) snippetDeletePost(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.snippets.Delete(r.Context(), models.DeleteSnippetParams{
+		ID:     s.ID,
+		UserID: s.UserID,
+	})
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+			return
+		}
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully deleted")
+
+	http.Redirect(w, r, "/user/snippets", http.StatusSeeOther)
+}
+
+func (app *application) tagView(w http.ResponseWriter, r *http.Request) {
+	tag := strings.ToLower(r.PathValue("name"))
+	if !validator.TagRX.MatchString(tag) {
+		http.NotFound(w, r)
+		return
+	}
+
+	params, pageNum, err := readPageParams(r)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+	params.Tag = tag
+
+	page, err := app.snippets.Page(r.Context(), params)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Tag = tag
+	data.Snippets = page.Snippets
+	data.Pagination = newPagination(r, page, pageNum)
+
+	app.render(w, r, http.StatusOK, "tag.tmpl", data)
+}
+
+func (app *application) search(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if !validator.MaxChars(query, 100) {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	params, pageNum, err := readPageParams(r)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	page, err := app.snippets.Search(r.Context(), models.SearchParams{
+		Query: query,
+		Page:  params,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Query = query
+	data.Snippets = page.Snippets
+	data.Pagination = newPagination(r, page, pageNum)
+
+	app.render(w, r, http.StatusOK, "search.tmpl", data)
+}
+
+func (app *application) userSnippets(w http.ResponseWriter, r *http.Request) {
+	params, pageNum, err := readPageParams(r)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+	params.UserID = app.authenticatedUserID(r)
+
+	page, err := app.snippets.Page(r.Context(), params)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippets = page.Snippets
+	data.Pagination = newPagination(r, page, pageNum)
+
+	app.render(w, r, http.StatusOK, "snippets.tmpl", data)
+}
+
+func (app *application) userTokens(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = tokenCreateForm{}
+	data.NewToken = app.sessionManager.PopString(r.Context(), "newToken")
+
+	app.renderTokens(w, r, http.StatusOK, data)
+}
+
+// renderTokens loads the token list for the settings page, both the page itself and the failed form use it
+func (app *application) renderTokens(w http.ResponseWriter, r *http.Request, status int, data templateData) {
+	tokens, err := app.tokens.ForUser(r.Context(), models.TokensParams{UserID: app.authenticatedUserID(r)})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	data.Tokens = tokens
+
+	app.render(w, r, status, "tokens.tmpl", data)
+}
+
+func (app *application) userTokenCreatePost(w http.ResponseWriter, r *http.Request) {
+	var form tokenCreateForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 100), "name", "This field cannot be more than 100 characters long")
 
 	if !form.Valid() {
 		data := app.newTemplateData(r)
 		data.Form = form
-		app.render(w, r, http.StatusBadRequest, "create.tmpl", data)
+		app.renderTokens(w, r, http.StatusUnprocessableEntity, data)
 		return
 	}
 
-	id, err := app.snippets.Insert(models.InsertSnippetParams{
-		Title:   form.Title,
-		Content: form.Content,
-		Expires: form.Expires,
+	token, err := app.tokens.Insert(r.Context(), models.InsertTokenParams{
+		UserID: app.authenticatedUserID(r),
+		Name:   form.Name,
 	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// the plain token goes through the session once so a refresh of the page doesn't create another one
+	app.sessionManager.Put(r.Context(), "newToken", token)
+	app.sessionManager.Put(r.Context(), "flash", "Token created, copy it now, you won't be able to see it again")
 
+	http.Redirect(w, r, "/user/tokens", http.StatusSeeOther)
+}
+
+func (app *application) userTokenRevokePost(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	err = app.tokens.Delete(r.Context(), models.DeleteTokenParams{ID: id, UserID: app.authenticatedUserID(r)})
 	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+			return
+		}
 		app.serverError(w, r, err)
 		return
 	}
 
-	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully created")
+	app.sessionManager.Put(r.Context(), "flash", "Token revoked")
 
-	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
+	http.Redirect(w, r, "/user/tokens", http.StatusSeeOther)
 }
 
 func (app *application) userSignup(w http.ResponseWriter, r *http.Request) {
@@ -151,7 +989,7 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 		app.serverError(w, r, err)
 	}
 
-	err = app.users.Insert(models.InsertUserParams{
+	err = app.users.Insert(r.Context(), models.InsertUserParams{
 		Name:     form.Name,
 		Email:    form.Email,
 		Password: string(hashedPassword),
@@ -200,7 +1038,7 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	uId, err := app.users.Authenticate(models.AuthenticateUserParams{Email: form.Email, Password: form.Password})
+	uId, err := app.users.Authenticate(r.Context(), models.AuthenticateUserParams{Email: form.Email, Password: form.Password})
 	if err != nil {
 		if errors.Is(err, models.ErrInvalidCredentials) {
 			form.AddNonFieldError("Invalid email or password")
@@ -2,12 +2,23 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/ByChanderZap/snippetbox/internal/assert"
+	"github.com/ByChanderZap/snippetbox/internal/models"
 )
 
 func TestPing(t *testing.T) {
@@ -36,3 +47,429 @@ func TestPing(t *testing.T) {
 
 	assert.Equal(t, "OK", string(body))
 }
+
+func TestSnippetFormExpiry(t *testing.T) {
+	now := time.Date(2024, 3, 17, 10, 15, 0, 0, time.UTC)
+	// an expiry later in the current minute, the edit form cuts it to a time that already passed
+	current := now.Add(30 * time.Second)
+
+	tests := []struct {
+		name  string
+		form  snippetCreateForm
+		valid bool
+		want  string
+	}{
+		{name: "Preset", form: snippetCreateForm{Expires: "7d"}, valid: true, want: "2024-03-24T10:15"},
+		{name: "Never", form: snippetCreateForm{Expires: "never"}, valid: true, want: ""},
+		{name: "Custom hours", form: snippetCreateForm{Expires: "custom", ExpiresAmount: 36, ExpiresUnit: "hours"}, valid: true, want: "2024-03-18T22:15"},
+		{name: "Custom zero", form: snippetCreateForm{Expires: "custom", ExpiresAmount: 0, ExpiresUnit: "days"}, valid: false},
+		{name: "Custom bad unit", form: snippetCreateForm{Expires: "custom", ExpiresAmount: 3, ExpiresUnit: "weeks"}, valid: false},
+		{name: "Date", form: snippetCreateForm{Expires: "date", ExpiresAt: "2024-04-01T08:00"}, valid: true, want: "2024-04-01T08:00"},
+		{name: "Date in the past", form: snippetCreateForm{Expires: "date", ExpiresAt: "2024-03-01T08:00"}, valid: false},
+		{name: "Keep", form: snippetCreateForm{Expires: "keep", CurrentExpiry: &current}, valid: true, want: "2024-03-17T10:15"},
+		{name: "Keep without expiry", form: snippetCreateForm{Expires: "keep"}, valid: false},
+		{name: "Unchanged date", form: snippetCreateForm{Expires: "date", ExpiresAt: "2024-03-17T10:15", CurrentExpiry: &current}, valid: true, want: "2024-03-17T10:15"},
+		{name: "Changed date in the past", form: snippetCreateForm{Expires: "date", ExpiresAt: "2024-03-17T10:00", CurrentExpiry: &current}, valid: false},
+		{name: "Unknown", form: snippetCreateForm{Expires: "3"}, valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.form.validateExpiry(now)
+			assert.Equal(t, tt.valid, tt.form.Valid())
+			if !tt.valid {
+				return
+			}
+
+			got := ""
+			if exp := tt.form.expiresAt(now); exp != nil {
+				got = exp.Format(expiresAtLayout)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestValidEnvelope(t *testing.T) {
+	payload := func(n int) string {
+		return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, n))
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    bool
+	}{
+		{name: "Valid", content: "v1:" + payload(gcmIVSize+gcmTagSize+5), want: true},
+		{name: "No version", content: payload(gcmIVSize + gcmTagSize + 5), want: false},
+		{name: "Unknown version", content: "v2:" + payload(gcmIVSize+gcmTagSize+5), want: false},
+		{name: "Not base64", content: "v1:hello world", want: false},
+		{name: "Only iv and tag", content: "v1:" + payload(gcmIVSize+gcmTagSize), want: false},
+		{name: "Plain text", content: "package main", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, validEnvelope(tt.content))
+		})
+	}
+}
+
+func TestSnippetView(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	past := time.Now().Add(-time.Hour)
+	public := seedSnippet(t, app, models.Snippet{Title: "An old silent pond", Content: "A frog jumps into the pond", UserID: 1, Visibility: models.VisibilityPublic})
+	unlisted := seedSnippet(t, app, models.Snippet{Title: "Unlisted", Content: "not on the home page", UserID: 1, Visibility: models.VisibilityUnlisted})
+	private := seedSnippet(t, app, models.Snippet{Title: "Private", Content: "only for me", UserID: 1, Visibility: models.VisibilityPrivate})
+	expired := seedSnippet(t, app, models.Snippet{Title: "Expired", Content: "gone", UserID: 1, Visibility: models.VisibilityPublic, Expires: &past})
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+		wantLoc  string
+	}{
+		{name: "Public by slug", urlPath: "/s/" + public.Slug, wantCode: http.StatusOK, wantBody: "A frog jumps into the pond"},
+		{name: "Public by id", urlPath: "/snippet/view/" + strconv.Itoa(public.ID), wantCode: http.StatusMovedPermanently, wantLoc: "/s/" + public.Slug},
+		{name: "Unlisted by slug", urlPath: "/s/" + unlisted.Slug, wantCode: http.StatusOK, wantBody: "not on the home page"},
+		{name: "Unlisted by id", urlPath: "/snippet/view/" + strconv.Itoa(unlisted.ID), wantCode: http.StatusNotFound},
+		{name: "Private", urlPath: "/s/" + private.Slug, wantCode: http.StatusNotFound},
+		{name: "Expired", urlPath: "/s/" + expired.Slug, wantCode: http.StatusNotFound},
+		{name: "Missing slug", urlPath: "/s/nope", wantCode: http.StatusNotFound},
+		{name: "Negative id", urlPath: "/snippet/view/-1", wantCode: http.StatusNotFound},
+		{name: "String id", urlPath: "/snippet/view/foo", wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, tt.wantCode, code)
+			if tt.wantBody != "" {
+				assert.Equal(t, true, strings.Contains(body, tt.wantBody))
+			}
+			if tt.wantLoc != "" {
+				assert.Equal(t, tt.wantLoc, header.Get("Location"))
+			}
+		})
+	}
+}
+
+func TestSnippetOwnership(t *testing.T) {
+	edit := url.Values{
+		"title":      {"Taken over"},
+		"content":    {"not yours"},
+		"expires":    {"never"},
+		"language":   {"text"},
+		"visibility": {models.VisibilityPublic},
+	}
+
+	tests := []struct {
+		name    string
+		method  string
+		urlPath string
+		form    url.Values
+	}{
+		{name: "Edit form", method: http.MethodGet, urlPath: "/snippet/edit/%d"},
+		{name: "Update", method: http.MethodPost, urlPath: "/snippet/edit/%d", form: edit},
+		{name: "Delete", method: http.MethodPost, urlPath: "/snippet/delete/%d"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+
+			seedUser(t, app, "Alice", "alice@example.com", "pa$$word")
+			seedUser(t, app, "Bob", "bob@example.com", "pa$$word")
+			s := seedSnippet(t, app, models.Snippet{Title: "Mine", Content: "only alice edits this", UserID: 1, Visibility: models.VisibilityPublic})
+
+			ts.login(t, "bob@example.com", "pa$$word")
+
+			urlPath := fmt.Sprintf(tt.urlPath, s.ID)
+			var code int
+			if tt.method == http.MethodGet {
+				code, _, _ = ts.get(t, urlPath)
+			} else {
+				code, _, _ = ts.postForm(t, urlPath, tt.form)
+			}
+			assert.Equal(t, http.StatusForbidden, code)
+
+			got, err := app.snippets.Get(t.Context(), s.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, "Mine", got.Title)
+			assert.Equal(t, "only alice edits this", got.Content)
+		})
+	}
+}
+
+func TestHomeNegotiation(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	seedSnippet(t, app, models.Snippet{Title: "First", Content: "one", UserID: 1, Visibility: models.VisibilityPublic})
+	seedSnippet(t, app, models.Snippet{Title: "Hidden", Content: "two", UserID: 1, Visibility: models.VisibilityUnlisted})
+	seedSnippet(t, app, models.Snippet{Title: "Locked", Content: "three", UserID: 1, Visibility: models.VisibilityPublic, HashedPassword: []byte("hash")})
+	seedSnippet(t, app, models.Snippet{Title: "Burn", Content: "four", UserID: 1, Visibility: models.VisibilityPublic, ViewsLeft: 1})
+
+	code, header, body := ts.do(t, http.MethodGet, "/", http.Header{"Accept": {"application/json"}}, nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "application/json", header.Get("Content-Type"))
+	assert.Equal(t, true, slices.Contains(header.Values("Vary"), "Accept"))
+
+	var list snippetListJSON
+	if err := json.Unmarshal([]byte(body), &list); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 3, len(list.Snippets))
+
+	// only the open snippet hands out its content, the others need the unlock or reveal page
+	content := map[string]string{}
+	for _, s := range list.Snippets {
+		content[s.Title] = s.Content
+	}
+	assert.Equal(t, "one", content["First"])
+	assert.Equal(t, "", content["Locked"])
+	assert.Equal(t, "", content["Burn"])
+	_, ok := content["Hidden"]
+	assert.Equal(t, false, ok)
+
+	code, header, _ = ts.get(t, "/")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "text/html; charset=utf-8", header.Get("Content-Type"))
+}
+
+func TestPastePost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	token, err := app.tokens.Insert(t.Context(), models.InsertTokenParams{UserID: 1, Name: "laptop"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := http.Header{"Authorization": {"Bearer " + token}}
+
+	var multipartBody bytes.Buffer
+	mw := multipart.NewWriter(&multipartBody)
+	fw, err := mw.CreateFormFile("file", "main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(fw, "package main")
+	mw.WriteField("expires", "1h")
+	mw.Close()
+	multipartAuth := http.Header{"Authorization": auth["Authorization"], "Content-Type": {mw.FormDataContentType()}}
+
+	tests := []struct {
+		name     string
+		path     string
+		header   http.Header
+		body     string
+		wantCode int
+		// wantBody is checked when set, for created pastes the content is compared with it instead of body
+		wantBody  string
+		wantTitle string
+	}{
+		{name: "Raw body", path: "/paste", header: auth, body: "echo hi", wantCode: http.StatusCreated},
+		{name: "With fields", path: "/paste?title=hello&language=bash&expires=1h", header: auth, body: "echo hi", wantCode: http.StatusCreated},
+		{name: "Invalid field", path: "/paste?language=cobol", header: auth, body: "echo hi", wantCode: http.StatusBadRequest},
+		{
+			name:     "Invalid fields sorted",
+			path:     "/paste?language=cobol&visibility=secret&expires=soon",
+			header:   auth,
+			body:     "echo hi",
+			wantCode: http.StatusBadRequest,
+			wantBody: "expires: Please pick one of the listed expiry options\nlanguage: Please pick one of the listed languages\nvisibility: This field must be one of public, unlisted, private",
+		},
+		{name: "Multipart file", path: "/paste", header: multipartAuth, body: multipartBody.String(), wantCode: http.StatusCreated, wantBody: "package main", wantTitle: "main.go"},
+		{name: "Too large", path: "/paste", header: auth, body: strings.Repeat("a", maxPasteSize+1), wantCode: http.StatusRequestEntityTooLarge},
+		{name: "Empty body", path: "/paste", header: auth, body: "", wantCode: http.StatusBadRequest},
+		{name: "Content too large", path: "/paste", header: auth, body: strings.Repeat("a", maxContentSize+1), wantCode: http.StatusBadRequest},
+		{name: "No token", path: "/paste", body: "echo hi", wantCode: http.StatusUnauthorized},
+		{name: "Wrong token", path: "/paste", header: http.Header{"Authorization": {"Bearer nope"}}, body: "echo hi", wantCode: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.do(t, http.MethodPost, tt.path, tt.header, []byte(tt.body))
+			assert.Equal(t, tt.wantCode, code)
+
+			if tt.wantCode != http.StatusCreated {
+				if tt.wantBody != "" {
+					assert.Equal(t, tt.wantBody, body)
+				}
+				return
+			}
+
+			slug, ok := strings.CutPrefix(body, ts.URL+"/s/")
+			assert.Equal(t, true, ok)
+
+			s, err := app.snippets.GetBySlug(t.Context(), slug)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			wantContent := tt.body
+			if tt.wantBody != "" {
+				wantContent = tt.wantBody
+			}
+			assert.Equal(t, wantContent, s.Content)
+			assert.Equal(t, 1, s.UserID)
+			if tt.wantTitle != "" {
+				assert.Equal(t, tt.wantTitle, s.Title)
+			}
+		})
+	}
+}
+
+func TestAPISnippetList(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	seedSnippet(t, app, models.Snippet{Title: "Hello", Content: "package main", UserID: 1, Visibility: models.VisibilityPublic, Tags: []string{"go"}})
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		header       http.Header
+		wantCode     int
+		wantSnippets int
+		wantError    string
+	}{
+		{name: "All", urlPath: "/api/v1/snippets", wantCode: http.StatusOK, wantSnippets: 1},
+		{name: "Tag", urlPath: "/api/v1/snippets?tag=go", wantCode: http.StatusOK, wantSnippets: 1},
+		{name: "Uppercase tag", urlPath: "/api/v1/snippets?tag=GO", wantCode: http.StatusOK, wantSnippets: 1},
+		{name: "Other tag", urlPath: "/api/v1/snippets?tag=rust", wantCode: http.StatusOK, wantSnippets: 0},
+		{name: "Invalid tag", urlPath: "/api/v1/snippets?tag=a%20b", wantCode: http.StatusBadRequest, wantError: "tag can only contain lowercase letters, numbers and + # . _ -, up to 30 characters"},
+		{name: "Mine without token", urlPath: "/api/v1/snippets?mine=true", wantCode: http.StatusUnauthorized, wantError: "a valid bearer token is required"},
+		{name: "Invalid token", urlPath: "/api/v1/snippets", header: http.Header{"Authorization": {"Bearer nope"}}, wantCode: http.StatusUnauthorized, wantError: "a valid bearer token is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.do(t, http.MethodGet, tt.urlPath, tt.header, nil)
+			assert.Equal(t, tt.wantCode, code)
+			assert.Equal(t, "application/json", header.Get("Content-Type"))
+
+			if tt.wantError != "" {
+				var apiErr apiErrorJSON
+				if err := json.Unmarshal([]byte(body), &apiErr); err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, tt.wantError, apiErr.Error)
+				return
+			}
+
+			var list snippetListJSON
+			if err := json.Unmarshal([]byte(body), &list); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.wantSnippets, len(list.Snippets))
+		})
+	}
+}
+
+func TestAPISnippetListLockedContent(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	seedSnippet(t, app, models.Snippet{Title: "Locked", Content: "the password was needed", UserID: 1, Visibility: models.VisibilityPublic, HashedPassword: []byte("hash")})
+	seedSnippet(t, app, models.Snippet{Title: "Burn", Content: "read once", UserID: 1, Visibility: models.VisibilityPublic, ViewsLeft: 1})
+
+	token, err := app.tokens.Insert(t.Context(), models.InsertTokenParams{UserID: 1, Name: "ci"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		urlPath     string
+		header      http.Header
+		wantContent bool
+	}{
+		{name: "Anonymous", urlPath: "/api/v1/snippets", wantContent: false},
+		{name: "Author", urlPath: "/api/v1/snippets?mine=true", header: http.Header{"Authorization": {"Bearer " + token}}, wantContent: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.do(t, http.MethodGet, tt.urlPath, tt.header, nil)
+			assert.Equal(t, http.StatusOK, code)
+			assert.Equal(t, tt.wantContent, strings.Contains(body, "the password was needed"))
+			assert.Equal(t, tt.wantContent, strings.Contains(body, "read once"))
+
+			var list snippetListJSON
+			if err := json.Unmarshal([]byte(body), &list); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, 2, len(list.Snippets))
+		})
+	}
+}
+
+func TestAPISnippetCreate(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	token, err := app.tokens.Insert(t.Context(), models.InsertTokenParams{UserID: 1, Name: "ci"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := http.Header{"Authorization": {"Bearer " + token}, "Content-Type": {"application/json"}}
+
+	code, header, body := ts.do(t, http.MethodPost, "/api/v1/snippets", auth, []byte(`{"title": "Hello", "content": "package main", "language": "go", "tags": ["go"]}`))
+	assert.Equal(t, http.StatusCreated, code)
+
+	var s snippetJSON
+	if err := json.Unmarshal([]byte(body), &s); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "/api/v1/snippets/"+s.Slug, header.Get("Location"))
+	assert.Equal(t, "go", s.Language)
+	assert.Equal(t, "go", strings.Join(s.Tags, ","))
+
+	code, _, body = ts.do(t, http.MethodPost, "/api/v1/snippets", auth, []byte(`{"content": ""}`))
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+
+	var validation apiValidationErrorJSON
+	if err := json.Unmarshal([]byte(body), &validation); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "This field cannot be blank", validation.Errors["title"])
+
+	code, _, _ = ts.do(t, http.MethodDelete, "/api/v1/snippets/"+s.Slug, http.Header{"Authorization": {"Bearer " + token}}, nil)
+	assert.Equal(t, http.StatusNoContent, code)
+
+	code, _, _ = ts.get(t, "/api/v1/snippets/"+s.Slug)
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+// slowSnippetStore is a snippet store whose database never answers in time
+type slowSnippetStore struct {
+	models.SnippetStore
+}
+
+func (slowSnippetStore) GetBySlug(context.Context, string) (models.Snippet, error) {
+	return models.Snippet{}, fmt.Errorf("%w: context deadline exceeded", models.ErrTimeout)
+}
+
+func TestQueryTimeout(t *testing.T) {
+	app := newTestApplication(t)
+	app.snippets = slowSnippetStore{app.snippets}
+	ts := newTestServer(t, app.routes())
+
+	code, header, _ := ts.get(t, "/s/abcdefghij")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "5", header.Get("Retry-After"))
+
+	code, header, body := ts.get(t, "/api/v1/snippets/abcdefghij")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "5", header.Get("Retry-After"))
+	assert.Equal(t, "application/json", header.Get("Content-Type"))
+	assert.Equal(t, `{"error":"the database is busy, try again later"}`, body)
+}
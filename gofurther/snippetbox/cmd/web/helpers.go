@@ -2,18 +2,39 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"mime"
 	"net/http"
+	"net/url"
+	"path"
 	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/ByChanderZap/snippetbox/internal/models"
 	"github.com/go-playground/form/v4"
 	"github.com/justinas/nosurf"
 )
 
 func (app *application) serverError(w http.ResponseWriter, r *http.Request, err error) {
+	// the client went away, there is nobody to answer and nothing went wrong on our side
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	if errors.Is(err, models.ErrTimeout) {
+		app.serviceUnavailable(w, r, err)
+		return
+	}
+
 	var (
 		method = r.Method
 		uri    = r.URL.RequestURI()
@@ -22,13 +43,52 @@ func (app *application) serverError(w http.ResponseWriter, r *http.Request, err
 	)
 
 	app.logger.Error(err.Error(), slog.String("method", method), slog.String("uri", uri), slog.String("trace", trace))
+	if isAPIRequest(r) {
+		app.apiError(w, r, http.StatusInternalServerError, "internal server error")
+		return
+	}
 	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 }
 
+// serviceUnavailable answers a query that ran out of time, it isn't a bug so there is no trace
+// to log and the client can try again once the database catches up
+func (app *application) serviceUnavailable(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Warn(err.Error(), slog.String("method", r.Method), slog.String("uri", r.URL.RequestURI()))
+
+	w.Header().Set("Retry-After", "5")
+	if isAPIRequest(r) {
+		app.apiError(w, r, http.StatusServiceUnavailable, "the database is busy, try again later")
+		return
+	}
+	http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+}
+
 func (app *application) clientError(w http.ResponseWriter, r *http.Request, status int) {
 	http.Error(w, http.StatusText(status), status)
 }
 
+// tokenRequired is the 401 for token authenticated endpoints, it tells the client which scheme to use
+func (app *application) tokenRequired(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="snippetbox"`)
+	if isAPIRequest(r) {
+		app.apiError(w, r, http.StatusUnauthorized, "a valid bearer token is required")
+		return
+	}
+	app.clientError(w, r, http.StatusUnauthorized)
+}
+
+// isAPIRequest tells the /api/v1 routes apart, every error they send is json
+func isAPIRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/api/v1/")
+}
+
+// bearerToken returns the token of an "Authorization: Bearer <token>" header
+func bearerToken(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	token = strings.TrimSpace(token)
+	return token, ok && token != ""
+}
+
 func (app *application) render(w http.ResponseWriter, r *http.Request, status int, page string, data templateData) {
 	ts, ok := app.templatesCache[page]
 	if !ok {
@@ -49,11 +109,99 @@ func (app *application) render(w http.ResponseWriter, r *http.Request, status in
 	buf.WriteTo(w)
 }
 
+// respond is render for pages that can also be read by scripts, requests asking for json get v
+// encoded instead of the template
+func (app *application) respond(w http.ResponseWriter, r *http.Request, status int, page string, data templateData, v any) {
+	if wantsJSON(w, r) {
+		app.writeJSON(w, r, status, v)
+		return
+	}
+
+	app.render(w, r, status, page, data)
+}
+
+// wantsJSON is acceptsJSON for handlers that answer both ways, it sets Vary so caches keep
+// the html and json versions apart
+func wantsJSON(w http.ResponseWriter, r *http.Request) bool {
+	if !slices.Contains(w.Header().Values("Vary"), "Accept") {
+		w.Header().Add("Vary", "Accept")
+	}
+	return acceptsJSON(r)
+}
+
+// acceptsJSON reports whether the Accept header prefers application/json over html,
+// wildcards are ignored so browsers sending */* keep getting pages
+func acceptsJSON(r *http.Request) bool {
+	jsonQ, htmlQ := 0.0, 0.0
+
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+		}
+
+		switch mediaType {
+		case "application/json":
+			jsonQ = max(jsonQ, q)
+		case "text/html":
+			htmlQ = max(htmlQ, q)
+		}
+	}
+
+	return jsonQ > 0 && jsonQ >= htmlQ
+}
+
+// maxJSONSize caps request bodies read by readJSON
+const maxJSONSize = 1 << 20
+
+// writeJSON is the json counterpart of render, the body is encoded before the status is written
+// so an encoding error can still turn into a 500
+func (app *application) writeJSON(w http.ResponseWriter, r *http.Request, status int, data any) {
+	js, err := json.Marshal(data)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(js, '\n'))
+}
+
+// readJSON decodes a single json value into dst, unknown fields are rejected so typos in
+// field names don't get silently ignored
+func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxJSONSize)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err != nil {
+		return err
+	}
+
+	if dec.More() {
+		return errors.New("body must only contain a single JSON value")
+	}
+
+	return nil
+}
+
 func (app *application) newTemplateData(r *http.Request) templateData {
 	return templateData{
 		CurrentYear:     time.Now().Year(),
 		Flash:           app.sessionManager.PopString(r.Context(), "flash"),
 		IsAuthenticated: app.isAuthenticated(r),
+		AuthUserID:      app.authenticatedUserID(r),
 		CSRFToken:       nosurf.Token(r),
 	}
 }
@@ -86,3 +234,213 @@ func (app *application) isAuthenticated(r *http.Request) bool {
 	}
 	return isAuthenticated
 }
+
+// authenticatedUserID returns the id of the signed in user, or 0 when the request is anonymous
+func (app *application) authenticatedUserID(r *http.Request) int {
+	id, ok := r.Context().Value(authenticatedUserIDContextKey).(int)
+	if !ok {
+		return 0
+	}
+	return id
+}
+
+// snippetFromPath loads the snippet from either the {slug} or the {id} path value, when it
+// returns false a response has already been written. private snippets are hidden from everyone
+// but their owner and, since ids can be enumerated, unlisted ones are only reachable by slug
+func (app *application) snippetFromPath(w http.ResponseWriter, r *http.Request) (models.Snippet, bool) {
+	s, err := app.lookupSnippet(r)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+			return models.Snippet{}, false
+		}
+		app.serverError(w, r, err)
+		return models.Snippet{}, false
+	}
+
+	return s, true
+}
+
+// lookupSnippet loads the snippet named by the {slug} or {id} path value, snippets the current
+// user is not allowed to see come back as ErrNoRecord as well so nobody can tell they exist
+func (app *application) lookupSnippet(r *http.Request) (models.Snippet, error) {
+	var s models.Snippet
+	var err error
+
+	slug := r.PathValue("slug")
+	if slug != "" {
+		s, err = app.snippets.GetBySlug(r.Context(), slug)
+	} else {
+		id, convErr := strconv.Atoi(r.PathValue("id"))
+		if convErr != nil || id < 1 {
+			return models.Snippet{}, models.ErrNoRecord
+		}
+		s, err = app.snippets.Get(r.Context(), id)
+	}
+	if err != nil {
+		return models.Snippet{}, err
+	}
+
+	isOwner := s.UserID == app.authenticatedUserID(r)
+	if !isOwner && (s.Visibility == models.VisibilityPrivate || (slug == "" && s.Visibility != models.VisibilityPublic)) {
+		return models.Snippet{}, models.ErrNoRecord
+	}
+
+	return s, nil
+}
+
+func (app *application) ownedSnippet(w http.ResponseWriter, r *http.Request) (models.Snippet, bool) {
+	s, ok := app.snippetFromPath(w, r)
+	if !ok {
+		return models.Snippet{}, false
+	}
+
+	if s.UserID != app.authenticatedUserID(r) {
+		app.clientError(w, r, http.StatusForbidden)
+		return models.Snippet{}, false
+	}
+
+	return s, true
+}
+
+const snippetsPerPage = 10
+
+// readPageParams reads the ?cursor=, ?dir= and ?page= query values used by every paginated listing.
+// page is only a label for the user, the cursor is what actually picks the rows
+func readPageParams(r *http.Request) (models.PageParams, int, error) {
+	params := models.PageParams{Limit: snippetsPerPage}
+	pageNum := 1
+	qs := r.URL.Query()
+
+	if v := qs.Get("cursor"); v != "" {
+		cursor, err := strconv.Atoi(v)
+		if err != nil || cursor < 0 {
+			return models.PageParams{}, 0, fmt.Errorf("invalid cursor %q", v)
+		}
+		params.Cursor = cursor
+	}
+
+	params.Backward = qs.Get("dir") == "prev"
+
+	if v := qs.Get("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return models.PageParams{}, 0, fmt.Errorf("invalid page %q", v)
+		}
+		pageNum = n
+	}
+
+	return params, pageNum, nil
+}
+
+// newPagination builds the links for the pagination partial keeping any other query values
+// (like a search term) that the current request has
+func newPagination(r *http.Request, page models.SnippetPage, pageNum int) pagination {
+	p := pagination{Page: pageNum}
+
+	link := func(cursor int, dir string, n int) string {
+		qs := url.Values{}
+		for k, v := range r.URL.Query() {
+			qs[k] = v
+		}
+		qs.Set("cursor", strconv.Itoa(cursor))
+		qs.Set("page", strconv.Itoa(n))
+		qs.Del("dir")
+		if dir != "" {
+			qs.Set("dir", dir)
+		}
+		return r.URL.Path + "?" + qs.Encode()
+	}
+
+	if page.NextCursor != 0 {
+		p.NextURL = link(page.NextCursor, "", pageNum+1)
+	}
+	if page.PrevCursor != 0 {
+		p.PrevURL = link(page.PrevCursor, "prev", max(pageNum-1, 1))
+	}
+
+	return p
+}
+
+// parseTags turns the comma separated tags field into a clean list, lowercased and without duplicates
+func parseTags(value string) []string {
+	var tags []string
+	for _, t := range strings.Split(value, ",") {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t != "" && !slices.Contains(tags, t) {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// unlockedSessionKey is where the session remembers that a password protected snippet was unlocked
+func unlockedSessionKey(slug string) string {
+	return "unlockedSnippet:" + slug
+}
+
+// unlockVersion is stored under unlockedSessionKey, it changes with the password so setting
+// a new one locks out everyone who unlocked the old one. the hash itself stays out of the session
+func unlockVersion(s models.Snippet) string {
+	sum := sha256.Sum256(s.HashedPassword)
+	return hex.EncodeToString(sum[:])
+}
+
+// passwordLocked reports whether the snippet content must stay hidden until its password is entered,
+// owners never have to unlock their own snippets
+func (app *application) passwordLocked(r *http.Request, s models.Snippet) bool {
+	if len(s.HashedPassword) == 0 || s.UserID == app.authenticatedUserID(r) {
+		return false
+	}
+	return app.sessionManager.GetString(r.Context(), unlockedSessionKey(s.Slug)) != unlockVersion(s)
+}
+
+// downloadFilename builds a safe file name out of the snippet title and the extension of its language
+func downloadFilename(s models.Snippet) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s.Title) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+
+	name := strings.TrimSuffix(b.String(), "-")
+	if name == "" {
+		name = "snippet"
+	}
+
+	ext := "txt"
+	for _, l := range snippetLanguages {
+		if l.Value == s.Language {
+			ext = l.Ext
+			break
+		}
+	}
+
+	return name + "." + ext
+}
+
+// languageForFilename is the reverse of downloadFilename, it picks the language matching the extension
+func languageForFilename(name string) string {
+	ext := strings.ToLower(strings.TrimPrefix(path.Ext(name), "."))
+	for _, l := range snippetLanguages {
+		if l.Ext == ext {
+			return l.Value
+		}
+	}
+	return "text"
+}
+
+// absoluteURL is for responses read outside a browser, where a relative path is not much use
+func absoluteURL(r *http.Request, path string) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + path
+}
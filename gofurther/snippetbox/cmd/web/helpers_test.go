@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ByChanderZap/snippetbox/internal/assert"
+	"github.com/ByChanderZap/snippetbox/internal/models"
+)
+
+func TestNewPagination(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		page     models.SnippetPage
+		pageNum  int
+		wantPrev string
+		wantNext string
+	}{
+		{
+			name:     "First page",
+			url:      "/",
+			page:     models.SnippetPage{NextCursor: 41},
+			pageNum:  1,
+			wantNext: "/?cursor=41&page=2",
+		},
+		{
+			name:     "Middle page keeps other values",
+			url:      "/search?q=go&cursor=41&page=2",
+			page:     models.SnippetPage{PrevCursor: 40, NextCursor: 31},
+			pageNum:  2,
+			wantPrev: "/search?cursor=40&dir=prev&page=1&q=go",
+			wantNext: "/search?cursor=31&page=3&q=go",
+		},
+		{
+			name:    "Single page",
+			url:     "/",
+			page:    models.SnippetPage{},
+			pageNum: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.url, nil)
+			p := newPagination(r, tt.page, tt.pageNum)
+
+			assert.Equal(t, tt.pageNum, p.Page)
+			assert.Equal(t, tt.wantPrev, p.PrevURL)
+			assert.Equal(t, tt.wantNext, p.NextURL)
+		})
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{name: "Empty", value: "", want: ""},
+		{name: "Trims and lowercases", value: " Go ,  SQL", want: "go|sql"},
+		{name: "Drops blanks and duplicates", value: "go,,go, GO ,c++", want: "go|c++"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, strings.Join(parseTags(tt.value), "|"))
+		})
+	}
+}
+
+func TestDownloadFilename(t *testing.T) {
+	tests := []struct {
+		name    string
+		snippet models.Snippet
+		want    string
+	}{
+		{name: "Simple", snippet: models.Snippet{Title: "Hello World", Language: "go"}, want: "hello-world.go"},
+		{name: "Symbols", snippet: models.Snippet{Title: "  ../etc/passwd!!  ", Language: "text"}, want: "etc-passwd.txt"},
+		{name: "Only symbols", snippet: models.Snippet{Title: "???", Language: "python"}, want: "snippet.py"},
+		{name: "Unknown language", snippet: models.Snippet{Title: "notes", Language: "cobol"}, want: "notes.txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, downloadFilename(tt.snippet))
+		})
+	}
+}
+
+func TestLanguageForFilename(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		want     string
+	}{
+		{name: "Go", filename: "main.go", want: "go"},
+		{name: "Upper case", filename: "SCRIPT.PY", want: "python"},
+		{name: "Nested path", filename: "src/lib.rs", want: "rust"},
+		{name: "Unknown", filename: "notes.xyz", want: "text"},
+		{name: "No extension", filename: "Makefile", want: "text"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, languageForFilename(tt.filename))
+		})
+	}
+}
+
+func TestAcceptsJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept string
+		want   bool
+	}{
+		{name: "Empty", accept: "", want: false},
+		{name: "JSON", accept: "application/json", want: true},
+		{name: "Browser", accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", want: false},
+		{name: "Wildcard", accept: "*/*", want: false},
+		{name: "JSON preferred", accept: "text/html;q=0.5, application/json", want: true},
+		{name: "HTML preferred", accept: "application/json;q=0.5, text/html", want: false},
+		{name: "JSON refused", accept: "application/json;q=0", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := http.NewRequest(http.MethodGet, "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.Header.Set("Accept", tt.accept)
+
+			assert.Equal(t, tt.want, acceptsJSON(r))
+		})
+	}
+}
+
+func TestServerError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode int
+		wantLog  bool
+	}{
+		{name: "Error", err: errors.New("boom"), wantCode: http.StatusInternalServerError, wantLog: true},
+		{name: "Timeout", err: fmt.Errorf("%w: context deadline exceeded", models.ErrTimeout), wantCode: http.StatusServiceUnavailable, wantLog: true},
+		{name: "Canceled", err: fmt.Errorf("query: %w", context.Canceled), wantCode: http.StatusOK, wantLog: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			app := newTestApplication(t)
+			app.logger = slog.New(slog.NewTextHandler(&logs, nil))
+
+			rr := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/s/abcdefghij", nil)
+
+			app.serverError(rr, r, tt.err)
+
+			assert.Equal(t, tt.wantCode, rr.Code)
+			assert.Equal(t, tt.wantLog, logs.Len() > 0)
+			if !tt.wantLog {
+				assert.Equal(t, "", rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestPasswordLocked(t *testing.T) {
+	app := newTestApplication(t)
+
+	ctx, err := app.sessionManager.Load(t.Context(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/s/abcdefghij", nil).WithContext(ctx)
+
+	s := models.Snippet{Slug: "abcdefghij", UserID: 2, HashedPassword: []byte("first hash")}
+	assert.Equal(t, true, app.passwordLocked(r, s))
+
+	app.sessionManager.Put(ctx, unlockedSessionKey(s.Slug), unlockVersion(s))
+	assert.Equal(t, false, app.passwordLocked(r, s))
+
+	// a new password needs a new unlock
+	s.HashedPassword = []byte("second hash")
+	assert.Equal(t, true, app.passwordLocked(r, s))
+
+	s.HashedPassword = nil
+	assert.Equal(t, false, app.passwordLocked(r, s))
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -12,7 +13,9 @@ import (
 
 func commonHeader(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("content-Security-Policy", "default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com")
+		// scripts are only ever loaded from our own /static/js, encrypted snippets rely on that: no inline
+		// script or third party origin can run next to the decrypted content or read the key in the fragment
+		w.Header().Set("content-Security-Policy", "default-src 'self'; script-src 'self'; connect-src 'self'; object-src 'none'; base-uri 'none'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com")
 
 		w.Header().Set("Referrer-Policy", "origin-when-cross-origin")
 
@@ -89,15 +92,32 @@ func (app *application) preventCSRF(next http.Handler) http.Handler {
 	})
 }
 
+// authenticate reads the user from the session, or from an "Authorization: Bearer" token so scripts
+// can use the same routes as the browser. a token that doesn't match is rejected right away instead of
+// falling back to an anonymous request, otherwise a typo would look like a permissions problem
 func (app *application) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		id := app.sessionManager.GetInt(r.Context(), "authenticatedUserId")
+
+		if token, ok := bearerToken(r); ok && id == 0 {
+			var err error
+			id, err = app.tokens.Authenticate(r.Context(), models.AuthenticateTokenParams{Token: token})
+			if err != nil {
+				if errors.Is(err, models.ErrInvalidCredentials) {
+					app.tokenRequired(w, r)
+					return
+				}
+				app.serverError(w, r, err)
+				return
+			}
+		}
+
 		if id == 0 {
 			next.ServeHTTP(w, r)
 			return
 		}
 
-		exists, err := app.users.Exists(models.ExistsParams{
+		exists, err := app.users.Exists(r.Context(), models.ExistsParams{
 			ID: id,
 		})
 		if err != nil {
@@ -107,6 +127,7 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 
 		if exists {
 			ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+			ctx = context.WithValue(ctx, authenticatedUserIDContextKey, id)
 			r = r.WithContext(ctx)
 		}
 
@@ -114,6 +135,48 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 	})
 }
 
+// authenticateToken is authenticate for routes used by scripts, which have no session and don't go through
+// nosurf. a request without a token stays anonymous, a token that doesn't match is a 401
+func (app *application) authenticateToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Authorization")
+
+		token, ok := bearerToken(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		id, err := app.tokens.Authenticate(r.Context(), models.AuthenticateTokenParams{Token: token})
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidCredentials) {
+				app.tokenRequired(w, r)
+				return
+			}
+			app.serverError(w, r, err)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+		ctx = context.WithValue(ctx, authenticatedUserIDContextKey, id)
+		w.Header().Add("Cache-Control", "no-store")
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requireToken is requireAuth for token routes, there is no login page to send scripts to
+func (app *application) requireToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.isAuthenticated(r) {
+			app.tokenRequired(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 // this can be done to allow some origins for post requests
 // func (app *application) preventCSRF(next http.Handler) http.Handler {
 // 	cop := http.NewCrossOriginProtection()
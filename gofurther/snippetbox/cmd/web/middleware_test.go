@@ -26,7 +26,7 @@ func TestCommonHeaders(t *testing.T) {
 	res := rr.Result()
 	defer res.Body.Close()
 
-	expectedValue := "default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com"
+	expectedValue := "default-src 'self'; script-src 'self'; connect-src 'self'; object-src 'none'; base-uri 'none'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com"
 	assert.Equal(t, expectedValue, res.Header.Get("Content-Security-Policy"))
 
 	expectedValue = "origin-when-cross-origin"
@@ -53,3 +53,39 @@ func TestCommonHeaders(t *testing.T) {
 
 	assert.Equal(t, "OK", string(body))
 }
+
+func TestRequireTokenWithoutHeader(t *testing.T) {
+	app := &application{}
+
+	tests := []struct {
+		name          string
+		authorization string
+	}{
+		{name: "Missing", authorization: ""},
+		{name: "Basic scheme", authorization: "Basic dXNlcjpwYXNz"},
+		{name: "Empty token", authorization: "Bearer "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+
+			req, err := http.NewRequest(http.MethodPost, "/paste", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				t.Error("next handler should not be called")
+			})
+
+			app.authenticateToken(app.requireToken(next)).ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusUnauthorized, rr.Code)
+			assert.Equal(t, `Bearer realm="snippetbox"`, rr.Header().Get("WWW-Authenticate"))
+		})
+	}
+}
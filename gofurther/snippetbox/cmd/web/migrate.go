@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ByChanderZap/snippetbox/internal/models"
+)
+
+const migrateUsage = "usage: web [flags] migrate up|down|status"
+
+// runMigrate is the "migrate" subcommand, it uses the same -db-driver and -dsn as the server:
+//
+//	go run ./cmd/web -db-driver=sqlite migrate up
+func runMigrate(ctx context.Context, migrator *models.MigrationModel, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errors.New(migrateUsage)
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, mig := range applied {
+			fmt.Fprintf(out, "applied %04d_%s\n", mig.Version, mig.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Fprintln(out, "already up to date")
+		}
+
+	case "down":
+		mig, err := migrator.Down(ctx)
+		if errors.Is(err, models.ErrNoRecord) {
+			fmt.Fprintln(out, "nothing to roll back")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "rolled back %04d_%s\n", mig.Version, mig.Name)
+
+	case "status":
+		migrations, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED")
+		for _, mig := range migrations {
+			applied := "pending"
+			if mig.Applied != nil {
+				applied = mig.Applied.UTC().Format(time.DateTime)
+			}
+			fmt.Fprintf(tw, "%04d\t%s\t%s\n", mig.Version, mig.Name, applied)
+		}
+		return tw.Flush()
+
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	return nil
+}
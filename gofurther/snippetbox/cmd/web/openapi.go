@@ -0,0 +1,375 @@
+package main
+
+import (
+	"maps"
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the types below only cover the bits of OpenAPI 3 we use, the spec is built in code so the form and
+// json schemas come straight from the structs the handlers decode and can't drift from them
+
+type openAPIDoc struct {
+	OpenAPI    string                                 `json:"openapi"`
+	Info       openAPIInfo                            `json:"info"`
+	Paths      map[string]map[string]openAPIOperation `json:"paths"`
+	Components openAPIComponents                      `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIComponents struct {
+	Schemas         map[string]*openAPISchema        `json:"schemas"`
+	SecuritySchemes map[string]openAPISecurityScheme `json:"securitySchemes"`
+}
+
+type openAPISecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+	In     string `json:"in,omitempty"`
+	Name   string `json:"name,omitempty"`
+}
+
+type openAPIOperation struct {
+	Summary     string                     `json:"summary"`
+	Tags        []string                   `json:"tags,omitempty"`
+	Parameters  []openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+	Security    []map[string][]string      `json:"security,omitempty"`
+}
+
+type openAPIParameter struct {
+	Name     string         `json:"name"`
+	In       string         `json:"in"`
+	Required bool           `json:"required,omitempty"`
+	Schema   *openAPISchema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Headers     map[string]openAPIHeader    `json:"headers,omitempty"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIHeader struct {
+	Description string         `json:"description,omitempty"`
+	Schema      *openAPISchema `json:"schema"`
+}
+
+type openAPIMediaType struct {
+	Schema *openAPISchema `json:"schema"`
+}
+
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Nullable             bool                      `json:"nullable,omitempty"`
+	Properties           map[string]*openAPISchema `json:"properties,omitempty"`
+	Items                *openAPISchema            `json:"items,omitempty"`
+	AdditionalProperties *openAPISchema            `json:"additionalProperties,omitempty"`
+}
+
+// operation is how routes are described below, everything else is filled in by openAPISpec
+type operation struct {
+	method  string
+	pattern string
+	summary string
+	tag     string
+	// auth is "session", "token" or empty when the route is public
+	auth  string
+	query []string
+	// form is a struct decoded by formDecoder, json one decoded by readJSON
+	form any
+	json any
+	// body is the media type of a raw request body, like the one of /paste
+	body      string
+	responses []openAPIResponseSpec
+	// noDatabase routes never query the database, every other one answers 503 when a query times out
+	noDatabase bool
+}
+
+type openAPIResponseSpec struct {
+	status      int
+	contentType string
+	schema      any
+	// negotiated responses are html unless json is asked for, schema describes the json one
+	negotiated bool
+}
+
+func htmlResponses(statuses ...int) []openAPIResponseSpec {
+	return contentResponses("text/html; charset=utf-8", statuses...)
+}
+
+func textResponses(statuses ...int) []openAPIResponseSpec {
+	return contentResponses("text/plain; charset=utf-8", statuses...)
+}
+
+func contentResponses(contentType string, statuses ...int) []openAPIResponseSpec {
+	var specs []openAPIResponseSpec
+	for _, status := range statuses {
+		specs = append(specs, openAPIResponseSpec{status: status, contentType: contentType})
+	}
+	return specs
+}
+
+func responseOf(status int, contentType string, schema any) openAPIResponseSpec {
+	return openAPIResponseSpec{status: status, contentType: contentType, schema: schema}
+}
+
+// negotiated is a page that app.respond can also send as json
+func negotiated(status int, schema any) openAPIResponseSpec {
+	return openAPIResponseSpec{status: status, contentType: "text/html; charset=utf-8", schema: schema, negotiated: true}
+}
+
+// bareResponses are redirects and the like, where the body doesn't matter
+func bareResponses(statuses ...int) []openAPIResponseSpec {
+	var specs []openAPIResponseSpec
+	for _, status := range statuses {
+		specs = append(specs, openAPIResponseSpec{status: status})
+	}
+	return specs
+}
+
+var pageQuery = []string{"cursor", "dir", "page"}
+
+// operations lists every route of routes.go, TestOpenAPISpec fails when one is missing
+var operations = []operation{
+	{method: "GET", pattern: "/static/", summary: "Static assets", tag: "static", responses: bareResponses(200, 404), noDatabase: true},
+	{method: "GET", pattern: "/{$}", summary: "Latest public snippets, as json with Accept: application/json", tag: "snippets", query: pageQuery, responses: []openAPIResponseSpec{negotiated(200, snippetListJSON{}), responseOf(400, "text/plain; charset=utf-8", nil)}},
+	{method: "GET", pattern: "/s/{slug}", summary: "View a snippet, as json with Accept: application/json", tag: "snippets", responses: []openAPIResponseSpec{negotiated(200, snippetJSON{}), responseOf(403, "application/json", apiErrorJSON{}), responseOf(404, "text/plain; charset=utf-8", nil)}},
+	{method: "POST", pattern: "/s/{slug}/unlock", summary: "Unlock a password protected snippet", tag: "snippets", form: snippetUnlockForm{}, responses: append(bareResponses(303), htmlResponses(400, 404)...)},
+	{method: "POST", pattern: "/s/{slug}/reveal", summary: "Reveal a burn after reading snippet", tag: "snippets", responses: htmlResponses(200, 404)},
+	{method: "GET", pattern: "/s/{slug}/history", summary: "Revision history of a snippet", tag: "snippets", responses: append(bareResponses(303), htmlResponses(200, 404)...)},
+	{method: "GET", pattern: "/s/{slug}/diff", summary: "Diff between two revisions", tag: "snippets", query: []string{"from", "to"}, responses: append(bareResponses(303), htmlResponses(200, 400, 404)...)},
+	{method: "GET", pattern: "/s/{slug}/raw", summary: "Snippet content as plain text", tag: "snippets", responses: textResponses(200, 403, 404)},
+	{method: "GET", pattern: "/s/{slug}/download", summary: "Download the snippet content as a file", tag: "snippets", responses: textResponses(200, 403, 404)},
+	{method: "GET", pattern: "/snippet/view/{id}", summary: "Redirect to the snippet slug", tag: "snippets", responses: bareResponses(301, 404)},
+	{method: "GET", pattern: "/snippet/raw/{id}", summary: "Public snippet content as plain text", tag: "snippets", responses: textResponses(200, 403, 404)},
+	{method: "GET", pattern: "/snippet/download/{id}", summary: "Download public snippet content as a file", tag: "snippets", responses: textResponses(200, 403, 404)},
+	{method: "GET", pattern: "/snippet/view/{id}/history", summary: "Revision history of a public snippet", tag: "snippets", responses: append(bareResponses(303), htmlResponses(200, 404)...)},
+	{method: "GET", pattern: "/snippet/view/{id}/diff", summary: "Diff between two revisions of a public snippet", tag: "snippets", query: []string{"from", "to"}, responses: append(bareResponses(303), htmlResponses(200, 400, 404)...)},
+	{method: "GET", pattern: "/tag/{name}", summary: "Public snippets with a tag", tag: "snippets", query: pageQuery, responses: htmlResponses(200, 400)},
+	{method: "GET", pattern: "/search", summary: "Search public snippets", tag: "snippets", query: append([]string{"q"}, pageQuery...), responses: htmlResponses(200, 400)},
+	{method: "GET", pattern: "/user/signup", summary: "Signup form", tag: "users", responses: htmlResponses(200)},
+	{method: "POST", pattern: "/user/signup", summary: "Create an account", tag: "users", form: userSignupForm{}, responses: append(bareResponses(303), htmlResponses(400)...)},
+	{method: "GET", pattern: "/user/login", summary: "Login form", tag: "users", responses: htmlResponses(200)},
+	{method: "POST", pattern: "/user/login", summary: "Start a session", tag: "users", form: userSignInForm{}, responses: append(bareResponses(303), htmlResponses(400, 422)...)},
+	{method: "GET", pattern: "/snippet/create", summary: "Create snippet form", tag: "snippets", auth: "session", responses: append(bareResponses(303), htmlResponses(200)...)},
+	{method: "POST", pattern: "/snippet/create", summary: "Create a snippet", tag: "snippets", auth: "session", form: snippetCreateForm{}, responses: append(bareResponses(303), htmlResponses(400)...)},
+	{method: "GET", pattern: "/snippet/create/encrypted", summary: "Create encrypted snippet form", tag: "snippets", auth: "session", responses: append(bareResponses(303), htmlResponses(200)...)},
+	{method: "POST", pattern: "/snippet/create/encrypted", summary: "Create a snippet encrypted in the browser", tag: "snippets", auth: "session", form: snippetCreateForm{}, responses: append(bareResponses(303), htmlResponses(400)...)},
+	{method: "GET", pattern: "/snippet/edit/{id}", summary: "Edit snippet form", tag: "snippets", auth: "session", responses: append(bareResponses(303), htmlResponses(200, 403, 404)...)},
+	{method: "POST", pattern: "/snippet/edit/{id}", summary: "Update a snippet", tag: "snippets", auth: "session", form: snippetCreateForm{}, responses: append(bareResponses(303), htmlResponses(400, 403, 404)...)},
+	{method: "POST", pattern: "/snippet/delete/{id}", summary: "Delete a snippet", tag: "snippets", auth: "session", responses: bareResponses(303, 403, 404)},
+	{method: "GET", pattern: "/user/snippets", summary: "Snippets of the signed in user", tag: "users", auth: "session", query: pageQuery, responses: append(bareResponses(303), htmlResponses(200, 400)...)},
+	{method: "GET", pattern: "/user/tokens", summary: "API tokens of the signed in user", tag: "users", auth: "session", responses: append(bareResponses(303), htmlResponses(200)...)},
+	{method: "POST", pattern: "/user/tokens", summary: "Create an API token", tag: "users", auth: "session", form: tokenCreateForm{}, responses: append(bareResponses(303), htmlResponses(422)...)},
+	{method: "POST", pattern: "/user/tokens/revoke/{id}", summary: "Revoke an API token", tag: "users", auth: "session", responses: bareResponses(303, 404)},
+	{method: "POST", pattern: "/user/logout", summary: "End the session", tag: "users", auth: "session", responses: bareResponses(303)},
+	{method: "POST", pattern: "/paste", summary: "Create a snippet from the request body and get its URL back", tag: "api", auth: "token", body: "text/plain", responses: textResponses(201, 400, 401, 413)},
+	{method: "GET", pattern: "/api/v1/snippets", summary: "List public snippets, or your own with mine=true", tag: "api", query: []string{"cursor", "dir", "limit", "tag", "mine"}, responses: []openAPIResponseSpec{responseOf(200, "application/json", snippetListJSON{}), responseOf(400, "application/json", apiErrorJSON{}), responseOf(401, "application/json", apiErrorJSON{})}},
+	{method: "GET", pattern: "/api/v1/snippets/{slug}", summary: "Get a snippet", tag: "api", responses: []openAPIResponseSpec{responseOf(200, "application/json", snippetJSON{}), responseOf(401, "application/json", apiErrorJSON{}), responseOf(403, "application/json", apiErrorJSON{}), responseOf(404, "application/json", apiErrorJSON{})}},
+	{method: "POST", pattern: "/api/v1/snippets", summary: "Create a snippet", tag: "api", auth: "token", json: snippetRequest{}, responses: []openAPIResponseSpec{responseOf(201, "application/json", snippetJSON{}), responseOf(400, "application/json", apiErrorJSON{}), responseOf(401, "application/json", apiErrorJSON{}), responseOf(422, "application/json", apiValidationErrorJSON{})}},
+	{method: "PUT", pattern: "/api/v1/snippets/{slug}", summary: "Replace a snippet", tag: "api", auth: "token", json: snippetRequest{}, responses: []openAPIResponseSpec{responseOf(200, "application/json", snippetJSON{}), responseOf(400, "application/json", apiErrorJSON{}), responseOf(401, "application/json", apiErrorJSON{}), responseOf(403, "application/json", apiErrorJSON{}), responseOf(404, "application/json", apiErrorJSON{}), responseOf(409, "application/json", apiErrorJSON{}), responseOf(422, "application/json", apiValidationErrorJSON{})}},
+	{method: "DELETE", pattern: "/api/v1/snippets/{slug}", summary: "Delete a snippet", tag: "api", auth: "token", responses: []openAPIResponseSpec{{status: 204}, responseOf(401, "application/json", apiErrorJSON{}), responseOf(403, "application/json", apiErrorJSON{}), responseOf(404, "application/json", apiErrorJSON{})}},
+	{method: "GET", pattern: "/api/openapi.json", summary: "This document", tag: "api", responses: []openAPIResponseSpec{responseOf(200, "application/json", nil)}, noDatabase: true},
+}
+
+var pathParamRX = regexp.MustCompile(`\{([a-zA-Z_]+)\}`)
+
+// openAPIPath turns a ServeMux pattern into an OpenAPI path, "/{$}" is just "/" and
+// a trailing slash matches everything below it
+func openAPIPath(pattern string) string {
+	if pattern == "/{$}" {
+		return "/"
+	}
+	if strings.HasSuffix(pattern, "/") {
+		return pattern + "{path}"
+	}
+	return pattern
+}
+
+func openAPISpec() openAPIDoc {
+	doc := openAPIDoc{
+		OpenAPI: "3.0.3",
+		Info:    openAPIInfo{Title: "Snippetbox", Version: "1.0.0"},
+		Paths:   map[string]map[string]openAPIOperation{},
+		Components: openAPIComponents{
+			Schemas: map[string]*openAPISchema{},
+			SecuritySchemes: map[string]openAPISecurityScheme{
+				"session": {Type: "apiKey", In: "cookie", Name: "session"},
+				"token":   {Type: "http", Scheme: "bearer"},
+			},
+		},
+	}
+
+	for _, o := range operations {
+		path := openAPIPath(o.pattern)
+
+		op := openAPIOperation{
+			Summary:   o.summary,
+			Responses: map[string]openAPIResponse{},
+		}
+		if o.tag != "" {
+			op.Tags = []string{o.tag}
+		}
+		if o.auth != "" {
+			op.Security = []map[string][]string{{o.auth: {}}}
+		}
+
+		for _, m := range pathParamRX.FindAllStringSubmatch(path, -1) {
+			schema := &openAPISchema{Type: "string"}
+			if m[1] == "id" {
+				schema = &openAPISchema{Type: "integer"}
+			}
+			op.Parameters = append(op.Parameters, openAPIParameter{Name: m[1], In: "path", Required: true, Schema: schema})
+		}
+		for _, q := range o.query {
+			op.Parameters = append(op.Parameters, openAPIParameter{Name: q, In: "query", Schema: &openAPISchema{Type: "string"}})
+		}
+
+		switch {
+		case o.form != nil:
+			schema := schemaOf(reflect.TypeOf(o.form), "form", doc.Components.Schemas)
+			schema.Properties["csrf_token"] = &openAPISchema{Type: "string"}
+			op.RequestBody = &openAPIRequestBody{Required: true, Content: map[string]openAPIMediaType{
+				"application/x-www-form-urlencoded": {Schema: schema},
+			}}
+		case o.json != nil:
+			op.RequestBody = &openAPIRequestBody{Required: true, Content: map[string]openAPIMediaType{
+				"application/json": {Schema: schemaRef(reflect.TypeOf(o.json), doc.Components.Schemas)},
+			}}
+		case o.body != "":
+			file := schemaOf(reflect.TypeOf(struct {
+				File string `form:"file"`
+			}{}), "form", doc.Components.Schemas)
+			file.Properties["file"].Format = "binary"
+			op.RequestBody = &openAPIRequestBody{Required: true, Content: map[string]openAPIMediaType{
+				o.body:                {Schema: &openAPISchema{Type: "string"}},
+				"multipart/form-data": {Schema: file},
+			}}
+			// the remaining fields of the create form are read from the query string
+			fields := schemaOf(reflect.TypeOf(snippetCreateForm{}), "form", doc.Components.Schemas).Properties
+			for _, name := range slices.Sorted(maps.Keys(fields)) {
+				if name != "content" {
+					op.Parameters = append(op.Parameters, openAPIParameter{Name: name, In: "query", Schema: fields[name]})
+				}
+			}
+		}
+
+		for _, res := range o.responses {
+			r := openAPIResponse{Description: http.StatusText(res.status)}
+			switch {
+			case res.negotiated:
+				r.Content = map[string]openAPIMediaType{
+					res.contentType:    {Schema: &openAPISchema{Type: "string"}},
+					"application/json": {Schema: schemaRef(reflect.TypeOf(res.schema), doc.Components.Schemas)},
+				}
+			case res.contentType != "":
+				var schema *openAPISchema
+				if res.schema == nil {
+					schema = &openAPISchema{Type: "string"}
+				} else {
+					schema = schemaRef(reflect.TypeOf(res.schema), doc.Components.Schemas)
+				}
+				r.Content = map[string]openAPIMediaType{res.contentType: {Schema: schema}}
+			}
+			op.Responses[strconv.Itoa(res.status)] = r
+		}
+
+		// see serviceUnavailable
+		if !o.noDatabase {
+			r := openAPIResponse{
+				Description: http.StatusText(http.StatusServiceUnavailable),
+				Headers: map[string]openAPIHeader{
+					"Retry-After": {Description: "Seconds to wait before trying again", Schema: &openAPISchema{Type: "integer"}},
+				},
+			}
+			if strings.HasPrefix(o.pattern, "/api/v1/") {
+				r.Content = map[string]openAPIMediaType{"application/json": {Schema: schemaRef(reflect.TypeOf(apiErrorJSON{}), doc.Components.Schemas)}}
+			} else {
+				r.Content = map[string]openAPIMediaType{"text/plain; charset=utf-8": {Schema: &openAPISchema{Type: "string"}}}
+			}
+			op.Responses[strconv.Itoa(http.StatusServiceUnavailable)] = r
+		}
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]openAPIOperation{}
+		}
+		doc.Paths[path][strings.ToLower(o.method)] = op
+	}
+
+	return doc
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaRef adds json structs to the components so they are only described once
+func schemaRef(t reflect.Type, components map[string]*openAPISchema) *openAPISchema {
+	if t.Kind() != reflect.Struct || t == timeType {
+		return schemaOf(t, "json", components)
+	}
+
+	name := strings.TrimSuffix(t.Name(), "JSON")
+	name = strings.ToUpper(name[:1]) + name[1:]
+	if _, ok := components[name]; !ok {
+		// reserve the name first, a struct referencing itself would recurse forever otherwise
+		components[name] = nil
+		components[name] = schemaOf(t, "json", components)
+	}
+	return &openAPISchema{Ref: "#/components/schemas/" + name}
+}
+
+// schemaOf describes t reading field names from the given struct tag, "form" or "json"
+func schemaOf(t reflect.Type, tag string, components map[string]*openAPISchema) *openAPISchema {
+	if t.Kind() == reflect.Pointer {
+		s := schemaOf(t.Elem(), tag, components)
+		s.Nullable = true
+		return s
+	}
+
+	switch {
+	case t == timeType:
+		return &openAPISchema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.String:
+		return &openAPISchema{Type: "string"}
+	case t.Kind() == reflect.Bool:
+		return &openAPISchema{Type: "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return &openAPISchema{Type: "integer"}
+	case t.Kind() == reflect.Slice:
+		return &openAPISchema{Type: "array", Items: schemaRef(t.Elem(), components)}
+	case t.Kind() == reflect.Map:
+		return &openAPISchema{Type: "object", AdditionalProperties: schemaRef(t.Elem(), components)}
+	}
+
+	schema := &openAPISchema{Type: "object", Properties: map[string]*openAPISchema{}}
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		schema.Properties[name] = schemaRef(f.Type, components)
+	}
+	return schema
+}
+
+func (app *application) openAPI(w http.ResponseWriter, r *http.Request) {
+	app.writeJSON(w, r, http.StatusOK, openAPISpec())
+}
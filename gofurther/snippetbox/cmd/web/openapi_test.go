@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ByChanderZap/snippetbox/internal/assert"
+)
+
+// routePatterns reads the patterns passed to mux.Handle and mux.HandleFunc in routes.go, going
+// through the source means a new route can't be forgotten here the way it could in a hand kept list
+func routePatterns(t *testing.T) []string {
+	t.Helper()
+
+	file, err := parser.ParseFile(token.NewFileSet(), "routes.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var patterns []string
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) == 0 {
+			return true
+		}
+
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || (sel.Sel.Name != "Handle" && sel.Sel.Name != "HandleFunc") {
+			return true
+		}
+		if x, ok := sel.X.(*ast.Ident); !ok || x.Name != "mux" {
+			return true
+		}
+
+		lit, ok := call.Args[0].(*ast.BasicLit)
+		if !ok {
+			t.Errorf("route pattern is not a string literal: %#v", call.Args[0])
+			return true
+		}
+
+		pattern, err := strconv.Unquote(lit.Value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		patterns = append(patterns, pattern)
+		return true
+	})
+
+	return patterns
+}
+
+func TestOpenAPISpec(t *testing.T) {
+	spec := openAPISpec()
+
+	patterns := routePatterns(t)
+	if len(patterns) == 0 {
+		t.Fatal("no routes found in routes.go")
+	}
+
+	for _, pattern := range patterns {
+		t.Run(pattern, func(t *testing.T) {
+			method, path, ok := strings.Cut(pattern, " ")
+			if !ok {
+				t.Fatalf("route %q has no method", pattern)
+			}
+
+			_, ok = spec.Paths[openAPIPath(path)][strings.ToLower(method)]
+			assert.Equal(t, true, ok)
+		})
+	}
+
+	// a query running out of time turns into a 503 on every route that reaches the database
+	for _, o := range operations {
+		res, ok := spec.Paths[openAPIPath(o.pattern)][strings.ToLower(o.method)].Responses["503"]
+		assert.Equal(t, !o.noDatabase, ok)
+		if ok {
+			_, ok = res.Headers["Retry-After"]
+			assert.Equal(t, true, ok)
+		}
+	}
+
+	// and the other way around, the spec shouldn't describe routes that don't exist
+	assert.Equal(t, len(patterns), len(operations))
+
+	js, err := json.Marshal(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, true, json.Valid(js))
+}
+
+func TestOpenAPIPath(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{pattern: "/{$}", want: "/"},
+		{pattern: "/static/", want: "/static/{path}"},
+		{pattern: "/s/{slug}", want: "/s/{slug}"},
+		{pattern: "/api/openapi.json", want: "/api/openapi.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			assert.Equal(t, tt.want, openAPIPath(tt.pattern))
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/ByChanderZap/snippetbox/internal/models"
+)
+
+// reapExpiredSnippets deletes expired snippets every interval until ctx is cancelled.
+// each run keeps deleting batches until one comes back short, so a big backlog is
+// cleared in one go without a single huge DELETE
+func (app *application) reapExpiredSnippets(ctx context.Context, interval time.Duration, batchSize int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			app.logger.Info("snippet reaper stopped")
+			return
+		case <-ticker.C:
+			app.reapOnce(ctx, batchSize)
+		}
+	}
+}
+
+func (app *application) reapOnce(ctx context.Context, batchSize int) {
+	total := 0
+	for ctx.Err() == nil {
+		n, err := app.snippets.DeleteExpired(ctx, models.DeleteExpiredParams{Limit: batchSize})
+		if err != nil {
+			app.logger.Error("reaping expired snippets", "error", err.Error(), "deleted", total)
+			return
+		}
+
+		total += n
+		if n < batchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		app.logger.Info("reaped expired snippets", "deleted", total)
+	}
+}
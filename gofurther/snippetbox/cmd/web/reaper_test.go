@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ByChanderZap/snippetbox/internal/assert"
+	"github.com/ByChanderZap/snippetbox/internal/models"
+)
+
+func TestReapOnce(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name      string
+		batchSize int
+		// wantExpired is how many expired snippets are still stored afterwards
+		wantExpired int
+	}{
+		{name: "Batches until short", batchSize: 2, wantExpired: 0},
+		{name: "One batch", batchSize: 500, wantExpired: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			for range 5 {
+				seedSnippet(t, app, models.Snippet{Title: "Expired", Content: "Gone", Expires: &past})
+			}
+			seedSnippet(t, app, models.Snippet{Title: "Live", Content: "Here", Expires: &future})
+
+			app.reapOnce(t.Context(), tt.batchSize)
+
+			// a second DeleteExpired with a big limit counts what the reaper left behind
+			left, err := app.snippets.DeleteExpired(t.Context(), models.DeleteExpiredParams{Limit: 100})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.wantExpired, left)
+		})
+	}
+}
@@ -17,7 +17,20 @@ func (app *application) routes() http.Handler {
 	dynamic := alice.New(app.sessionManager.LoadAndSave, app.preventCSRF, app.authenticate)
 
 	mux.Handle("GET /{$}", dynamic.ThenFunc(app.home))
+	mux.Handle("GET /s/{slug}", dynamic.ThenFunc(app.snippetView))
+	mux.Handle("POST /s/{slug}/unlock", dynamic.ThenFunc(app.snippetUnlockPost))
+	mux.Handle("POST /s/{slug}/reveal", dynamic.ThenFunc(app.snippetRevealPost))
+	mux.Handle("GET /s/{slug}/history", dynamic.ThenFunc(app.snippetHistory))
+	mux.Handle("GET /s/{slug}/diff", dynamic.ThenFunc(app.snippetDiff))
+	mux.Handle("GET /s/{slug}/raw", dynamic.ThenFunc(app.snippetRaw))
+	mux.Handle("GET /s/{slug}/download", dynamic.ThenFunc(app.snippetDownload))
 	mux.Handle("GET /snippet/view/{id}", dynamic.ThenFunc(app.snippetView))
+	mux.Handle("GET /snippet/raw/{id}", dynamic.ThenFunc(app.snippetRaw))
+	mux.Handle("GET /snippet/download/{id}", dynamic.ThenFunc(app.snippetDownload))
+	mux.Handle("GET /snippet/view/{id}/history", dynamic.ThenFunc(app.snippetHistory))
+	mux.Handle("GET /snippet/view/{id}/diff", dynamic.ThenFunc(app.snippetDiff))
+	mux.Handle("GET /tag/{name}", dynamic.ThenFunc(app.tagView))
+	mux.Handle("GET /search", dynamic.ThenFunc(app.search))
 	mux.Handle("GET /user/signup", dynamic.ThenFunc(app.userSignup))
 	mux.Handle("POST /user/signup", dynamic.ThenFunc(app.userSignupPost))
 	mux.Handle("GET /user/login", dynamic.ThenFunc(app.userLogin))
@@ -29,8 +42,28 @@ func (app *application) routes() http.Handler {
 	authRoutes := dynamic.Append(app.requireAuth)
 	mux.Handle("GET /snippet/create", authRoutes.ThenFunc(app.snippetCreateForm))
 	mux.Handle("POST /snippet/create", authRoutes.ThenFunc(app.snippetCreatePost))
+	mux.Handle("GET /snippet/create/encrypted", authRoutes.ThenFunc(app.snippetCreateEncryptedForm))
+	mux.Handle("POST /snippet/create/encrypted", authRoutes.ThenFunc(app.snippetCreateEncryptedPost))
+	mux.Handle("GET /snippet/edit/{id}", authRoutes.ThenFunc(app.snippetEditForm))
+	mux.Handle("POST /snippet/edit/{id}", authRoutes.ThenFunc(app.snippetEditPost))
+	mux.Handle("POST /snippet/delete/{id}", authRoutes.ThenFunc(app.snippetDeletePost))
+	mux.Handle("GET /user/snippets", authRoutes.ThenFunc(app.userSnippets))
+	mux.Handle("GET /user/tokens", authRoutes.ThenFunc(app.userTokens))
+	mux.Handle("POST /user/tokens", authRoutes.ThenFunc(app.userTokenCreatePost))
+	mux.Handle("POST /user/tokens/revoke/{id}", authRoutes.ThenFunc(app.userTokenRevokePost))
 	mux.Handle("POST /user/logout", authRoutes.ThenFunc(app.userLogoutPost))
 
+	// Routes for scripts, these authenticate with a token and skip the session and csrf middlewares
+	api := alice.New(app.authenticateToken)
+	tokenRoutes := api.Append(app.requireToken)
+	mux.Handle("POST /paste", tokenRoutes.ThenFunc(app.pastePost))
+	mux.Handle("GET /api/v1/snippets", api.ThenFunc(app.apiSnippetList))
+	mux.Handle("GET /api/v1/snippets/{slug}", api.ThenFunc(app.apiSnippetGet))
+	mux.Handle("POST /api/v1/snippets", tokenRoutes.ThenFunc(app.apiSnippetCreate))
+	mux.Handle("PUT /api/v1/snippets/{slug}", tokenRoutes.ThenFunc(app.apiSnippetUpdate))
+	mux.Handle("DELETE /api/v1/snippets/{slug}", tokenRoutes.ThenFunc(app.apiSnippetDelete))
+	mux.HandleFunc("GET /api/openapi.json", app.openAPI)
+
 	standardMiddlewares := alice.New(app.recoverPanic, app.logRequest, commonHeader)
 	return standardMiddlewares.Then(mux)
 }
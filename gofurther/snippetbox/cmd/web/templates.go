@@ -1,24 +1,57 @@
 package main
 
 import (
+	"bytes"
+	"fmt"
 	"html/template"
 	"io/fs"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/ByChanderZap/snippetbox/internal/diff"
 	"github.com/ByChanderZap/snippetbox/internal/models"
 	"github.com/ByChanderZap/snippetbox/ui"
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
 )
 
+type snippetDiff struct {
+	From  models.Revision
+	To    models.Revision
+	Lines []diff.Line
+}
+
+// pagination feeds the "pagination" partial, empty urls hide the matching link
+type pagination struct {
+	Page    int
+	PrevURL string
+	NextURL string
+}
+
 type templateData struct {
-	Snippet         models.Snippet
-	Snippets        []models.Snippet
-	User            models.User
-	Users           []models.User
+	Snippet    models.Snippet
+	Snippets   []models.Snippet
+	Revisions  []models.Revision
+	Diff       snippetDiff
+	Pagination pagination
+	Query      string
+	Tag        string
+	Burned     bool
+	User       models.User
+	Users      []models.User
+	Tokens     []models.Token
+	// NewToken is only set right after a token is created, it is never shown again
+	NewToken        string
 	CurrentYear     int
 	Form            any
 	Flash           string
 	IsAuthenticated bool
+	AuthUserID      int
 	CSRFToken       string
 }
 
@@ -30,8 +63,202 @@ func humanDate(t time.Time) string {
 	return t.UTC().Format("02 Jan 2006 at 15:04")
 }
 
+func plural(n int, unit string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", unit)
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+// relativeExpiry describes how far away t is from now, like "in 3 days"
+func relativeExpiry(t *time.Time, now time.Time) string {
+	if t == nil {
+		return "never"
+	}
+
+	d := t.Sub(now)
+	switch {
+	case d <= 0:
+		return "expired"
+	case d < time.Minute:
+		return "in less than a minute"
+	case d < time.Hour:
+		return "in " + plural(int(d/time.Minute), "minute")
+	case d < 48*time.Hour:
+		return "in " + plural(int(d/time.Hour), "hour")
+	default:
+		return "in " + plural(int(d/(24*time.Hour)), "day")
+	}
+}
+
+func expiresIn(t *time.Time) string {
+	return relativeExpiry(t, time.Now())
+}
+
+// diffClass maps a diff operation to the css class used to colour the line
+func diffClass(op diff.Op) string {
+	switch op {
+	case diff.Insert:
+		return "add"
+	case diff.Delete:
+		return "del"
+	default:
+		return ""
+	}
+}
+
+// searchRX builds a case insensitive regexp matching any of the words in a search query,
+// it returns nil when there is nothing to look for
+func searchRX(query string) *regexp.Regexp {
+	terms := models.SearchTerms(query)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	for i, t := range terms {
+		terms[i] = regexp.QuoteMeta(t)
+	}
+
+	return regexp.MustCompile("(?i)" + strings.Join(terms, "|"))
+}
+
+// highlight escapes text and wraps every word of the search query in a <mark> tag
+func highlight(text, query string) template.HTML {
+	rx := searchRX(query)
+	if rx == nil {
+		return template.HTML(template.HTMLEscapeString(text))
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, loc := range rx.FindAllStringIndex(text, -1) {
+		b.WriteString(template.HTMLEscapeString(text[last:loc[0]]))
+		b.WriteString("<mark>")
+		b.WriteString(template.HTMLEscapeString(text[loc[0]:loc[1]]))
+		b.WriteString("</mark>")
+		last = loc[1]
+	}
+	b.WriteString(template.HTMLEscapeString(text[last:]))
+
+	return template.HTML(b.String())
+}
+
+const excerptLength = 200
+
+// excerpt cuts a window of text around the first match of the search query
+// so search results don't dump the whole snippet
+func excerpt(text, query string) string {
+	if len(text) <= excerptLength {
+		return text
+	}
+
+	start := 0
+	if rx := searchRX(query); rx != nil {
+		if loc := rx.FindStringIndex(text); loc != nil {
+			start = max(loc[0]-excerptLength/4, 0)
+		}
+	}
+	end := min(start+excerptLength, len(text))
+
+	// never cut a multi byte character in half
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start--
+	}
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end--
+	}
+
+	out := text[start:end]
+	if start > 0 {
+		out = "…" + out
+	}
+	if end < len(text) {
+		out += "…"
+	}
+	return out
+}
+
+type snippetLanguage struct {
+	Value string
+	Label string
+	// Ext is the file extension used when the snippet is downloaded
+	Ext string
+}
+
+// snippetLanguages are the options of the language selector, values are chroma lexer names
+var snippetLanguages = []snippetLanguage{
+	{"text", "Plain text", "txt"},
+	{"bash", "Bash", "sh"},
+	{"c", "C", "c"},
+	{"csharp", "C#", "cs"},
+	{"cpp", "C++", "cpp"},
+	{"css", "CSS", "css"},
+	{"dockerfile", "Dockerfile", "dockerfile"},
+	{"go", "Go", "go"},
+	{"html", "HTML", "html"},
+	{"java", "Java", "java"},
+	{"javascript", "JavaScript", "js"},
+	{"json", "JSON", "json"},
+	{"markdown", "Markdown", "md"},
+	{"php", "PHP", "php"},
+	{"python", "Python", "py"},
+	{"ruby", "Ruby", "rb"},
+	{"rust", "Rust", "rs"},
+	{"sql", "SQL", "sql"},
+	{"typescript", "TypeScript", "ts"},
+	{"yaml", "YAML", "yaml"},
+}
+
+func languageValues() []string {
+	values := make([]string, len(snippetLanguages))
+	for i, l := range snippetLanguages {
+		values[i] = l.Value
+	}
+	return values
+}
+
+func languages() []snippetLanguage {
+	return snippetLanguages
+}
+
+// the formatter emits css classes instead of style attributes, inline styles would be
+// blocked by our Content-Security-Policy. the matching rules live in ui/static/css/chroma.css
+var (
+	syntaxFormatter = chromahtml.New(chromahtml.WithClasses(true))
+	syntaxStyle     = styles.Get("github")
+)
+
+// syntax renders content as a highlighted <pre> block, falling back to plain
+// escaped text if the language is unknown or the lexer fails
+func syntax(content, language string) template.HTML {
+	plain := template.HTML("<pre><code>" + template.HTMLEscapeString(content) + "</code></pre>")
+
+	lexer := lexers.Get(language)
+	if lexer == nil {
+		return plain
+	}
+
+	it, err := chroma.Coalesce(lexer).Tokenise(nil, content)
+	if err != nil {
+		return plain
+	}
+
+	buf := new(bytes.Buffer)
+	if err := syntaxFormatter.Format(buf, syntaxStyle, it); err != nil {
+		return plain
+	}
+
+	return template.HTML(buf.String())
+}
+
 var functions = template.FuncMap{
 	"humanDate": humanDate,
+	"expiresIn": expiresIn,
+	"diffClass": diffClass,
+	"highlight": highlight,
+	"excerpt":   excerpt,
+	"syntax":    syntax,
+	"languages": languages,
 }
 
 func newTemplateCache() (map[string]*template.Template, error) {
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"html/template"
+	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 
 	"github.com/ByChanderZap/snippetbox/internal/assert"
 )
@@ -41,3 +44,97 @@ func TestHumanDate(t *testing.T) {
 		})
 	}
 }
+
+func TestNewTemplateCache(t *testing.T) {
+	cache, err := newTemplateCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, page := range []string{"home.tmpl", "view.tmpl", "create.tmpl", "edit.tmpl", "history.tmpl", "diff.tmpl", "search.tmpl", "tag.tmpl", "create_encrypted.tmpl", "unlock.tmpl", "reveal.tmpl", "tokens.tmpl"} {
+		_, ok := cache[page]
+		assert.Equal(t, true, ok)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		query string
+		want  template.HTML
+	}{
+		{
+			name:  "No query",
+			text:  "<b>go</b>",
+			query: "",
+			want:  "&lt;b&gt;go&lt;/b&gt;",
+		},
+		{
+			name:  "Case insensitive",
+			text:  "Go is fun, go!",
+			query: "go",
+			want:  "<mark>Go</mark> is fun, <mark>go</mark>!",
+		},
+		{
+			name:  "Escapes around matches",
+			text:  "a < b && c",
+			query: "b c",
+			want:  "a &lt; <mark>b</mark> &amp;&amp; <mark>c</mark>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, highlight(tt.text, tt.query))
+		})
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	short := "just a short snippet"
+	assert.Equal(t, short, excerpt(short, "short"))
+
+	long := strings.Repeat("é", 300) + "needle" + strings.Repeat("x", 300)
+	got := excerpt(long, "needle")
+	assert.Equal(t, true, strings.Contains(got, "needle"))
+	assert.Equal(t, true, strings.HasPrefix(got, "…"))
+	assert.Equal(t, true, strings.HasSuffix(got, "…"))
+	assert.Equal(t, true, utf8.ValidString(got))
+}
+
+func TestSyntax(t *testing.T) {
+	got := string(syntax("package main", "go"))
+	assert.Equal(t, true, strings.Contains(got, `class="chroma"`))
+	assert.Equal(t, false, strings.Contains(got, "style="))
+
+	got = string(syntax("<script>", "not-a-language"))
+	assert.Equal(t, "<pre><code>&lt;script&gt;</code></pre>", got)
+}
+
+func TestRelativeExpiry(t *testing.T) {
+	now := time.Date(2024, 3, 17, 10, 15, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+
+	tests := []struct {
+		name string
+		tm   *time.Time
+		want string
+	}{
+		{name: "Never", tm: nil, want: "never"},
+		{name: "Past", tm: at(-time.Minute), want: "expired"},
+		{name: "Seconds", tm: at(30 * time.Second), want: "in less than a minute"},
+		{name: "One minute", tm: at(time.Minute), want: "in 1 minute"},
+		{name: "Hours", tm: at(5*time.Hour + 10*time.Minute), want: "in 5 hours"},
+		{name: "Days", tm: at(3*24*time.Hour + time.Hour), want: "in 3 days"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, relativeExpiry(tt.tm, now))
+		})
+	}
+}
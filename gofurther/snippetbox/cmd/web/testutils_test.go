@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ByChanderZap/snippetbox/internal/models"
+	"github.com/ByChanderZap/snippetbox/internal/models/mocks"
+	"github.com/alexedwards/scs/v2"
+	"github.com/go-playground/form/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newTestApplication wires the handlers to the in-memory stores of internal/models/mocks,
+// sessions live in the default scs memory store
+func newTestApplication(t *testing.T) *application {
+	t.Helper()
+
+	tCache, err := newTemplateCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessionManager := scs.New()
+	sessionManager.Lifetime = 12 * time.Hour
+	sessionManager.Cookie.Secure = true
+
+	snippets := mocks.NewSnippetStore()
+
+	return &application{
+		logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+		snippets:       snippets,
+		users:          mocks.NewUserStore(),
+		tokens:         &mocks.TokenStore{},
+		templatesCache: tCache,
+		formDecoder:    form.NewDecoder(),
+		sessionManager: sessionManager,
+	}
+}
+
+// seedSnippet stores s in the test application's snippet store and returns it with its id and slug
+func seedSnippet(t *testing.T, app *application, s models.Snippet) models.Snippet {
+	t.Helper()
+
+	id, slug, err := app.snippets.Insert(t.Context(), models.InsertSnippetParams{
+		Title:          s.Title,
+		Content:        s.Content,
+		Expires:        s.Expires,
+		MaxViews:       s.ViewsLeft,
+		UserID:         s.UserID,
+		Language:       s.Language,
+		Visibility:     s.Visibility,
+		HashedPassword: s.HashedPassword,
+		Encrypted:      s.Encrypted,
+		Tags:           s.Tags,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.ID = id
+	s.Slug = slug
+	return s
+}
+
+// seedUser stores a user that can log in with password, ids are handed out in order from 1
+func seedUser(t *testing.T, app *application, name, email, password string) {
+	t.Helper()
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = app.users.Insert(t.Context(), models.InsertUserParams{Name: name, Email: email, Password: string(hashedPassword)})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+type testServer struct {
+	*httptest.Server
+}
+
+// newTestServer starts a TLS server since the session cookie is Secure, redirects are not
+// followed so tests can check them
+func newTestServer(t *testing.T, h http.Handler) *testServer {
+	t.Helper()
+
+	ts := httptest.NewTLSServer(h)
+	t.Cleanup(ts.Close)
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.Client().Jar = jar
+
+	ts.Client().CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	return &testServer{ts}
+}
+
+// do sends a request to path with the given headers and returns the status, headers and body
+func (ts *testServer) do(t *testing.T, method, path string, header http.Header, body []byte) (int, http.Header, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, ts.URL+path, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return res.StatusCode, res.Header, string(bytes.TrimSpace(b))
+}
+
+func (ts *testServer) get(t *testing.T, path string) (int, http.Header, string) {
+	t.Helper()
+	return ts.do(t, http.MethodGet, path, nil, nil)
+}
+
+// postForm sends form urlencoded like a browser would
+func (ts *testServer) postForm(t *testing.T, path string, form url.Values) (int, http.Header, string) {
+	t.Helper()
+	return ts.do(t, http.MethodPost, path, http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}, []byte(form.Encode()))
+}
+
+// login signs in through the login form, the session cookie stays in the client jar
+func (ts *testServer) login(t *testing.T, email, password string) {
+	t.Helper()
+
+	code, header, _ := ts.postForm(t, "/user/login", url.Values{"email": {email}, "password": {password}})
+	if code != http.StatusSeeOther || !strings.HasPrefix(header.Get("Location"), "/snippet/create") {
+		t.Fatalf("login as %s failed with status %d", email, code)
+	}
+}
@@ -0,0 +1,115 @@
+package diff
+
+import (
+	"strings"
+)
+
+type Op int
+
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+// Line is a single line of a unified diff, OldLine and NewLine are 1 based and
+// left as 0 when the line does not exist on that side
+type Line struct {
+	Op      Op
+	Text    string
+	OldLine int
+	NewLine int
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// Lines computes a line based diff going from a to b.
+// common prefix and suffix are trimmed first so the LCS table only covers the changed
+// region, which for the usual "fixed a typo" edit keeps it tiny
+func Lines(a, b string) []Line {
+	as, bs := splitLines(a), splitLines(b)
+
+	prefix := 0
+	for prefix < len(as) && prefix < len(bs) && as[prefix] == bs[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(as)-prefix && suffix < len(bs)-prefix && as[len(as)-1-suffix] == bs[len(bs)-1-suffix] {
+		suffix++
+	}
+
+	var lines []Line
+	for i := 0; i < prefix; i++ {
+		lines = append(lines, Line{Op: Equal, Text: as[i], OldLine: i + 1, NewLine: i + 1})
+	}
+
+	ma, mb := as[prefix:len(as)-suffix], bs[prefix:len(bs)-suffix]
+
+	// the table grows with the product of both sides, past maxCells the changed region is
+	// shown as removed and added wholesale instead of letting a big paste eat the memory
+	if len(ma)*len(mb) > maxCells {
+		for i, text := range ma {
+			lines = append(lines, Line{Op: Delete, Text: text, OldLine: prefix + i + 1})
+		}
+		for j, text := range mb {
+			lines = append(lines, Line{Op: Insert, Text: text, NewLine: prefix + j + 1})
+		}
+	} else {
+		lines = append(lines, lcsLines(ma, mb, prefix)...)
+	}
+
+	for k := 0; k < suffix; k++ {
+		oi, ni := len(as)-suffix+k, len(bs)-suffix+k
+		lines = append(lines, Line{Op: Equal, Text: as[oi], OldLine: oi + 1, NewLine: ni + 1})
+	}
+
+	return lines
+}
+
+// maxCells caps the LCS table at about 8MB
+const maxCells = 1 << 20
+
+// lcsLines diffs the changed region, line numbers are offset by the prefix trimmed before it
+func lcsLines(ma, mb []string, prefix int) []Line {
+	var lines []Line
+
+	// lcs[i][j] holds the length of the longest common subsequence of ma[i:] and mb[j:]
+	lcs := make([][]int, len(ma)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(mb)+1)
+	}
+	for i := len(ma) - 1; i >= 0; i-- {
+		for j := len(mb) - 1; j >= 0; j-- {
+			if ma[i] == mb[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(ma) || j < len(mb) {
+		switch {
+		case i < len(ma) && j < len(mb) && ma[i] == mb[j]:
+			lines = append(lines, Line{Op: Equal, Text: ma[i], OldLine: prefix + i + 1, NewLine: prefix + j + 1})
+			i++
+			j++
+		case i < len(ma) && (j == len(mb) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, Line{Op: Delete, Text: ma[i], OldLine: prefix + i + 1})
+			i++
+		default:
+			lines = append(lines, Line{Op: Insert, Text: mb[j], NewLine: prefix + j + 1})
+			j++
+		}
+	}
+
+	return lines
+}
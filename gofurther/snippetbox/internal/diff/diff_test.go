@@ -0,0 +1,87 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ByChanderZap/snippetbox/internal/assert"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want string
+	}{
+		{
+			name: "Identical",
+			a:    "one\ntwo",
+			b:    "one\ntwo",
+			want: " one\n two\n",
+		},
+		{
+			name: "Changed line",
+			a:    "one\ntwo\nthree",
+			b:    "one\n2\nthree",
+			want: " one\n-two\n+2\n three\n",
+		},
+		{
+			name: "Appended",
+			a:    "one",
+			b:    "one\ntwo\n",
+			want: " one\n+two\n",
+		},
+		{
+			name: "From empty",
+			a:    "",
+			b:    "one",
+			want: "+one\n",
+		},
+		{
+			name: "CRLF",
+			a:    "one\r\ntwo",
+			b:    "one\ntwo",
+			want: " one\n two\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			for _, l := range Lines(tt.a, tt.b) {
+				switch l.Op {
+				case Insert:
+					got += "+"
+				case Delete:
+					got += "-"
+				default:
+					got += " "
+				}
+				got += l.Text + "\n"
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLinesLarge(t *testing.T) {
+	// 5000x5000 lines is far past maxCells, the whole middle is replaced
+	var a, b strings.Builder
+	for i := range 5000 {
+		fmt.Fprintf(&a, "old %d\n", i)
+		fmt.Fprintf(&b, "new %d\n", i)
+	}
+
+	lines := Lines("header\n"+a.String()+"footer", "header\n"+b.String()+"footer")
+
+	assert.Equal(t, 10002, len(lines))
+	assert.Equal(t, Equal, lines[0].Op)
+	assert.Equal(t, Delete, lines[1].Op)
+	assert.Equal(t, 2, lines[1].OldLine)
+	assert.Equal(t, Insert, lines[5001].Op)
+	assert.Equal(t, 2, lines[5001].NewLine)
+	assert.Equal(t, Equal, lines[10001].Op)
+	assert.Equal(t, 5002, lines[10001].NewLine)
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Dialect is what changes between the databases the models can run on. queries are written
+// once, for MySQL, and each dialect rewrites the few bits that differ (placeholders, the current
+// time, aggregates) or hands over its own statement when a rewrite would not be enough.
+// the methods are unexported so the only dialects are the ones in this package, use DialectFor
+type Dialect interface {
+	Name() string
+
+	// rebind adapts a query written for MySQL
+	rebind(query string) string
+	// isUniqueViolation reports whether err is a duplicate value on key
+	isUniqueViolation(err error, key uniqueKey) bool
+	// insertID runs an INSERT and returns the id of the row it created (or matched, for upserts)
+	insertID(ctx context.Context, db execQuerier, query string, args ...any) (int64, error)
+	// forUpdate locks the rows of a SELECT inside a transaction
+	forUpdate() string
+	// differs compares column to ? byte for byte, whatever the collation of the column
+	differs(column string) string
+	// like is a case insensitive LIKE on column where \ escapes the wildcards
+	like(column string) string
+	// fullText returns a filter matching every term against the title and content, ok is
+	// false when there is no full text index and Search should fall back to like
+	fullText(terms []string) (filter string, args []any, ok bool)
+	// upsertTag inserts a tag by name, or touches the existing one so insertID returns its id
+	upsertTag() string
+	// deleteExpired deletes at most ? expired snippets
+	deleteExpired() string
+	// createMigrations creates the schema_migrations table if it doesn't exist yet
+	createMigrations() string
+}
+
+// uniqueKey names a unique index the way each database reports it, MySQL and Postgres give the
+// constraint name while SQLite only lists the table.column it covers
+type uniqueKey struct {
+	constraint string
+	columns    string
+}
+
+var (
+	uniqueSnippetSlug = uniqueKey{constraint: "snippets_uc_slug", columns: "snippets.slug"}
+	uniqueUserEmail   = uniqueKey{constraint: "users_uc_email", columns: "users.email"}
+)
+
+// execQuerier is what *sql.DB and *sql.Tx have in common
+type execQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// DialectFor returns the dialect of a -db-driver value
+func DialectFor(driver string) (Dialect, error) {
+	switch driver {
+	case "mysql":
+		return MySQL, nil
+	case "sqlite":
+		return SQLite, nil
+	case "postgres":
+		return Postgres, nil
+	}
+	return nil, fmt.Errorf("models: unsupported database driver %q", driver)
+}
+
+// dialectOr lets the models be built without a dialect, that was all of them before there
+// was more than MySQL
+func dialectOr(d Dialect) Dialect {
+	if d == nil {
+		return MySQL
+	}
+	return d
+}
+
+// lastInsertID is insertID for drivers that report it without a RETURNING clause
+func lastInsertID(ctx context.Context, db execQuerier, query string, args ...any) (int64, error) {
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// returningID is insertID for databases that support INSERT ... RETURNING
+func returningID(ctx context.Context, db execQuerier, query string, args ...any) (int64, error) {
+	var id int64
+	err := db.QueryRowContext(ctx, strings.TrimSpace(query)+" RETURNING id", args...).Scan(&id)
+	return id, err
+}
+
+// likeTerms builds the LIKE fallback of Search, every term has to be in the title or the content
+func likeTerms(d Dialect, terms []string) (string, []any) {
+	var filter strings.Builder
+	var args []any
+
+	for _, t := range terms {
+		pattern := "%" + likeEscaper.Replace(t) + "%"
+		fmt.Fprintf(&filter, ` AND (%s OR %s)`, d.like("s.title"), d.like("s.content"))
+		args = append(args, pattern, pattern)
+	}
+
+	return filter.String(), args
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// MySQL is the dialect the queries are written in, so it doesn't have to rewrite anything
+var MySQL Dialect = mysqlDialect{}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string { return "mysql" }
+
+func (mysqlDialect) rebind(query string) string { return query }
+
+func (mysqlDialect) isUniqueViolation(err error, key uniqueKey) bool {
+	var mySQLError *mysql.MySQLError
+	return errors.As(err, &mySQLError) && mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, key.constraint)
+}
+
+func (mysqlDialect) insertID(ctx context.Context, db execQuerier, query string, args ...any) (int64, error) {
+	return lastInsertID(ctx, db, query, args...)
+}
+
+// the default collation ignores case and accents, BINARY compares the bytes instead
+func (mysqlDialect) differs(column string) string { return "BINARY " + column + " <> BINARY ?" }
+
+func (mysqlDialect) forUpdate() string { return " FOR UPDATE" }
+
+// the default collation is already case insensitive and \ is the default escape character
+func (mysqlDialect) like(column string) string { return column + " LIKE ?" }
+
+// every word is required and matched as a prefix through the FULLTEXT index on (title, content)
+func (mysqlDialect) fullText(terms []string) (string, []any, bool) {
+	boolean := make([]string, len(terms))
+	for i, t := range terms {
+		boolean[i] = "+" + t + "*"
+	}
+	return ` AND MATCH(s.title, s.content) AGAINST (? IN BOOLEAN MODE)`, []any{strings.Join(boolean, " ")}, true
+}
+
+// LAST_INSERT_ID(id) makes LastInsertId return the existing row id when the name is already taken
+func (mysqlDialect) upsertTag() string {
+	return `
+	INSERT INTO tags (name) VALUES (?)
+	ON DUPLICATE KEY UPDATE id = LAST_INSERT_ID(id)
+	`
+}
+
+func (mysqlDialect) deleteExpired() string {
+	return `DELETE FROM snippets WHERE expires IS NOT NULL AND expires <= UTC_TIMESTAMP() LIMIT ?`
+}
+
+func (mysqlDialect) createMigrations() string {
+	return `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER NOT NULL PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied DATETIME NOT NULL
+	)
+	`
+}
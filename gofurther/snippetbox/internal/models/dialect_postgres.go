@@ -0,0 +1,110 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Postgres stores times in TIMESTAMP columns holding UTC like the DATETIME ones of MySQL,
+// so the current time is taken in UTC as well
+var Postgres Dialect = postgresDialect{}
+
+// pgUniqueViolation is the SQLSTATE of a duplicate value on a unique index
+const pgUniqueViolation = "23505"
+
+var postgresReplacer = strings.NewReplacer(
+	"UTC_TIMESTAMP()", "(now() AT TIME ZONE 'utc')",
+	"GROUP_CONCAT(t.name ORDER BY t.name)", "string_agg(t.name, ',' ORDER BY t.name)",
+)
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string { return "postgres" }
+
+// rebind numbers the placeholders, none of the queries have a ? inside a string literal
+func (postgresDialect) rebind(query string) string {
+	query = postgresReplacer.Replace(query)
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (postgresDialect) isUniqueViolation(err error, key uniqueKey) bool {
+	var pgError *pgconn.PgError
+	return errors.As(err, &pgError) && pgError.Code == pgUniqueViolation && pgError.ConstraintName == key.constraint
+}
+
+func (postgresDialect) insertID(ctx context.Context, db execQuerier, query string, args ...any) (int64, error) {
+	return returningID(ctx, db, query, args...)
+}
+
+// the default deterministic collations already tell apart strings that differ in any byte
+func (postgresDialect) differs(column string) string { return column + " <> ?" }
+
+func (postgresDialect) forUpdate() string { return " FOR UPDATE" }
+
+// \ is already the default escape character of LIKE
+func (postgresDialect) like(column string) string { return column + " ILIKE ?" }
+
+// every word is required and matched as a prefix like the MySQL boolean mode search, through the
+// GIN index on the same to_tsvector expression. tsquery has its own operators so only letters and
+// digits are kept, a query left with nothing to search for falls back to LIKE
+func (postgresDialect) fullText(terms []string) (string, []any, bool) {
+	var prefixes []string
+	for _, t := range terms {
+		word := strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return r
+			}
+			return -1
+		}, t)
+		if word != "" {
+			prefixes = append(prefixes, word+":*")
+		}
+	}
+	if len(prefixes) == 0 {
+		return "", nil, false
+	}
+
+	return ` AND to_tsvector('simple', s.title || ' ' || s.content) @@ to_tsquery('simple', ?)`, []any{strings.Join(prefixes, " & ")}, true
+}
+
+func (postgresDialect) upsertTag() string {
+	return `
+	INSERT INTO tags (name) VALUES (?)
+	ON CONFLICT (name) DO UPDATE SET name = excluded.name
+	`
+}
+
+// DELETE has no LIMIT in Postgres either
+func (postgresDialect) deleteExpired() string {
+	return `
+	DELETE FROM snippets WHERE id IN (
+		SELECT id FROM snippets WHERE expires IS NOT NULL AND expires <= UTC_TIMESTAMP() LIMIT ?
+	)
+	`
+}
+
+func (postgresDialect) createMigrations() string {
+	return `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER NOT NULL PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied TIMESTAMP NOT NULL
+	)
+	`
+}
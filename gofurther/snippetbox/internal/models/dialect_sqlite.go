@@ -0,0 +1,80 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// SQLite is meant for development, it needs no server and the whole database is one file.
+// times are stored as text in the format _time_format=sqlite writes a UTC time.Time, so
+// comparing them as strings against sqliteNow orders them correctly
+var SQLite Dialect = sqliteDialect{}
+
+// SQLiteOptions are the driver options every sqlite DSN needs. foreign keys are off by default
+// in SQLite and the ON DELETE CASCADE of tags, revisions and tokens rely on them, immediate
+// transactions take the write lock up front which is what FOR UPDATE does for Reveal elsewhere
+const SQLiteOptions = "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_txlock=immediate&_time_format=sqlite&_texttotime=1"
+
+// SQLiteDSN is the default -dsn for the sqlite driver
+const SQLiteDSN = "file:snippetbox.db?" + SQLiteOptions
+
+const sqliteNow = `strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')`
+
+var sqliteReplacer = strings.NewReplacer("UTC_TIMESTAMP()", sqliteNow)
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string { return "sqlite" }
+
+func (sqliteDialect) rebind(query string) string { return sqliteReplacer.Replace(query) }
+
+// SQLite doesn't report constraint names, only the columns like "UNIQUE constraint failed: users.email"
+func (sqliteDialect) isUniqueViolation(err error, key uniqueKey) bool {
+	var sqliteError *sqlite.Error
+	return errors.As(err, &sqliteError) && sqliteError.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE && strings.Contains(sqliteError.Error(), key.columns)
+}
+
+// last_insert_rowid is not updated when an upsert ends up updating, RETURNING works for both
+func (sqliteDialect) insertID(ctx context.Context, db execQuerier, query string, args ...any) (int64, error) {
+	return returningID(ctx, db, query, args...)
+}
+
+// there are no row locks in SQLite, transactions are started with _txlock=immediate instead
+// the default BINARY collation already compares bytes
+func (sqliteDialect) differs(column string) string { return column + " <> ?" }
+
+func (sqliteDialect) forUpdate() string { return "" }
+
+// LIKE is already case insensitive for ASCII but it has no default escape character
+func (sqliteDialect) like(column string) string { return column + ` LIKE ? ESCAPE '\'` }
+
+func (sqliteDialect) fullText([]string) (string, []any, bool) { return "", nil, false }
+
+func (sqliteDialect) upsertTag() string {
+	return `
+	INSERT INTO tags (name) VALUES (?)
+	ON CONFLICT (name) DO UPDATE SET name = excluded.name
+	`
+}
+
+func (sqliteDialect) deleteExpired() string {
+	return `
+	DELETE FROM snippets WHERE id IN (
+		SELECT id FROM snippets WHERE expires IS NOT NULL AND expires <= UTC_TIMESTAMP() LIMIT ?
+	)
+	`
+}
+
+func (sqliteDialect) createMigrations() string {
+	return `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER NOT NULL PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied DATETIME NOT NULL
+	)
+	`
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ByChanderZap/snippetbox/internal/assert"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestPostgresRebind(t *testing.T) {
+	got := Postgres.rebind(`SELECT GROUP_CONCAT(t.name ORDER BY t.name) FROM tags t WHERE a = ? AND b > UTC_TIMESTAMP() LIMIT ?`)
+	want := `SELECT string_agg(t.name, ',' ORDER BY t.name) FROM tags t WHERE a = $1 AND b > (now() AT TIME ZONE 'utc') LIMIT $2`
+
+	assert.Equal(t, want, got)
+}
+
+func TestPostgresFullText(t *testing.T) {
+	tests := []struct {
+		name  string
+		terms []string
+		want  any
+		ok    bool
+	}{
+		{
+			name:  "Prefixes",
+			terms: []string{"frog", "pon"},
+			want:  "frog:* & pon:*",
+			ok:    true,
+		},
+		{
+			name:  "Operators",
+			terms: []string{"it's", "a|b!"},
+			want:  "its:* & ab:*",
+			ok:    true,
+		},
+		{
+			name:  "Nothing left",
+			terms: []string{"&", "!"},
+			ok:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, args, ok := Postgres.fullText(tt.terms)
+
+			assert.Equal(t, tt.ok, ok)
+			if ok {
+				assert.Equal(t, tt.want, args[0])
+			}
+		})
+	}
+}
+
+func TestIsUniqueViolation(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect Dialect
+		err     error
+		want    bool
+	}{
+		{
+			name:    "MySQL email",
+			dialect: MySQL,
+			err:     &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'a@example.com' for key 'users.users_uc_email'"},
+			want:    true,
+		},
+		{
+			name:    "MySQL other key",
+			dialect: MySQL,
+			err:     &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'x' for key 'tags.name'"},
+			want:    false,
+		},
+		{
+			name:    "Postgres email",
+			dialect: Postgres,
+			err:     fmt.Errorf("insert: %w", &pgconn.PgError{Code: "23505", ConstraintName: "users_uc_email"}),
+			want:    true,
+		},
+		{
+			name:    "Postgres other key",
+			dialect: Postgres,
+			err:     &pgconn.PgError{Code: "23505", ConstraintName: "tags_name_key"},
+			want:    false,
+		},
+		{
+			name:    "Postgres not null",
+			dialect: Postgres,
+			err:     &pgconn.PgError{Code: "23502", ConstraintName: "users_uc_email"},
+			want:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.dialect.isUniqueViolation(tt.err, uniqueUserEmail))
+		})
+	}
+}
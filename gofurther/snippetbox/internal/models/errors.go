@@ -8,4 +8,6 @@ var (
 	ErrNoRecord           = errors.New("models: no matching record found")
 	ErrInvalidCredentials = errors.New("models: invalid credentials")
 	ErrDuplicatedEmail    = errors.New("models: duplicate email")
+	// ErrTimeout means a query ran past its deadline, the database is overloaded rather than broken
+	ErrTimeout = errors.New("models: query timed out")
 )
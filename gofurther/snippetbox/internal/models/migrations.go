@@ -0,0 +1,236 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Migration is one versioned change to the schema, read from <version>_<name>.up.sql and
+// <version>_<name>.down.sql in the directory named after the dialect
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+	// Applied is when the migration ran, nil while it is pending
+	Applied *time.Time
+}
+
+type MigrationModel struct {
+	DB *sql.DB
+	// Dialect defaults to MySQL
+	Dialect Dialect
+	// Files has a directory of migrations per dialect name, see the migrations package
+	Files fs.FS
+}
+
+// load reads the migrations of the dialect sorted by version, every version needs both files
+func (m *MigrationModel) load() ([]Migration, error) {
+	dir := dialectOr(m.Dialect).Name()
+
+	paths, err := fs.Glob(m.Files, dir+"/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, p := range paths {
+		base := strings.TrimSuffix(path.Base(p), ".sql")
+
+		base, up := strings.CutSuffix(base, ".up")
+		if !up {
+			var down bool
+			base, down = strings.CutSuffix(base, ".down")
+			if !down {
+				return nil, fmt.Errorf("models: migration %s is neither .up.sql nor .down.sql", p)
+			}
+		}
+
+		v, name, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(v)
+		if err != nil || version <= 0 || name == "" {
+			return nil, fmt.Errorf("models: migration %s is not named <version>_<name>", p)
+		}
+
+		content, err := fs.ReadFile(m.Files, p)
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: name}
+			byVersion[version] = mig
+		}
+		if mig.Name != name {
+			return nil, fmt.Errorf("models: migration %d is named both %s and %s", version, mig.Name, name)
+		}
+
+		if up {
+			mig.Up = string(content)
+		} else {
+			mig.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("models: migration %d_%s needs both an .up.sql and a .down.sql", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int { return a.Version - b.Version })
+
+	return migrations, nil
+}
+
+const stmtAppliedMigrations = `SELECT version, applied FROM schema_migrations`
+
+// Status returns every migration with the time it was applied, creating the
+// schema_migrations table the first time
+func (m *MigrationModel) Status(ctx context.Context) ([]Migration, error) {
+	d := dialectOr(m.Dialect)
+
+	migrations, err := m.load()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = m.DB.ExecContext(ctx, d.createMigrations())
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := m.DB.QueryContext(ctx, d.rebind(stmtAppliedMigrations))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var t time.Time
+		if err := rows.Scan(&version, &t); err != nil {
+			return nil, err
+		}
+		applied[version] = t
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range migrations {
+		if t, ok := applied[migrations[i].Version]; ok {
+			migrations[i].Applied = &t
+			delete(applied, migrations[i].Version)
+		}
+	}
+
+	// a version we have no files for was applied by a newer build, going on could undo its changes
+	for version := range applied {
+		return nil, fmt.Errorf("models: migration %d is applied but unknown to this build", version)
+	}
+
+	return migrations, nil
+}
+
+const stmtRecordMigration = `INSERT INTO schema_migrations (version, name, applied) VALUES (?, ?, UTC_TIMESTAMP())`
+
+const stmtForgetMigration = `DELETE FROM schema_migrations WHERE version = ?`
+
+// Up applies every pending migration in order and returns the ones it applied
+func (m *MigrationModel) Up(ctx context.Context) ([]Migration, error) {
+	d := dialectOr(m.Dialect)
+
+	migrations, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, mig := range migrations {
+		if mig.Applied != nil {
+			continue
+		}
+
+		err = m.run(ctx, mig.Up, d.rebind(stmtRecordMigration), mig.Version, mig.Name)
+		if err != nil {
+			return applied, fmt.Errorf("models: migration %d_%s: %w", mig.Version, mig.Name, err)
+		}
+		applied = append(applied, mig)
+	}
+
+	return applied, nil
+}
+
+// Down rolls back the latest applied migration, ErrNoRecord means there was none
+func (m *MigrationModel) Down(ctx context.Context) (Migration, error) {
+	d := dialectOr(m.Dialect)
+
+	migrations, err := m.Status(ctx)
+	if err != nil {
+		return Migration{}, err
+	}
+
+	var mig Migration
+	for _, candidate := range slices.Backward(migrations) {
+		if candidate.Applied != nil {
+			mig = candidate
+			break
+		}
+	}
+	if mig.Applied == nil {
+		return Migration{}, ErrNoRecord
+	}
+
+	err = m.run(ctx, mig.Down, d.rebind(stmtForgetMigration), mig.Version)
+	if err != nil {
+		return Migration{}, fmt.Errorf("models: migration %d_%s: %w", mig.Version, mig.Name, err)
+	}
+
+	return mig, nil
+}
+
+// run executes a migration and records it in the same transaction. MySQL commits on every
+// CREATE or DROP so a failing migration can be left half done there, Postgres and SQLite roll
+// it back entirely. there is no QueryTimeout here, building an index on a big table can take a while
+func (m *MigrationModel) run(ctx context.Context, migration, record string, args ...any) error {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range splitStatements(migration) {
+		if _, err = tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	if _, err = tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// splitStatements runs statements one by one since MySQL only accepts several per Exec with
+// multiStatements=true, migrations have to end every statement with a ; at the end of a line
+func splitStatements(migration string) []string {
+	var stmts []string
+	for stmt := range strings.SplitSeq(migration, ";\n") {
+		if stmt = strings.TrimSpace(stmt); stmt != "" {
+			stmts = append(stmts, strings.TrimSuffix(stmt, ";"))
+		}
+	}
+	return stmts
+}
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/ByChanderZap/snippetbox/internal/assert"
+	"github.com/ByChanderZap/snippetbox/migrations"
+)
+
+func TestMigrationModel(t *testing.T) {
+	db, d := newTestDB(t)
+	migrator := &MigrationModel{DB: db, Dialect: d, Files: migrations.Files}
+
+	status, err := migrator.Status(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest := status[len(status)-1]
+	for _, mig := range status {
+		assert.Equal(t, true, mig.Applied != nil)
+	}
+
+	applied, err := migrator.Up(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 0, len(applied))
+
+	mig, err := migrator.Down(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, latest.Version, mig.Version)
+
+	status, err = migrator.Status(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, true, status[len(status)-1].Applied == nil)
+
+	applied, err = migrator.Up(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, len(applied))
+	assert.Equal(t, latest.Version, applied[0].Version)
+}
+
+func TestMigrationLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   fstest.MapFS
+		wantErr bool
+	}{
+		{
+			name: "Valid",
+			files: fstest.MapFS{
+				"mysql/0002_second.up.sql":   {Data: []byte("CREATE TABLE b (id INTEGER);")},
+				"mysql/0002_second.down.sql": {Data: []byte("DROP TABLE b;")},
+				"mysql/0001_first.up.sql":    {Data: []byte("CREATE TABLE a (id INTEGER);")},
+				"mysql/0001_first.down.sql":  {Data: []byte("DROP TABLE a;")},
+				"sqlite/0003_other.up.sql":   {Data: []byte("CREATE TABLE c (id INTEGER);")},
+			},
+		},
+		{
+			name: "Missing down",
+			files: fstest.MapFS{
+				"mysql/0001_first.up.sql": {Data: []byte("CREATE TABLE a (id INTEGER);")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "No version",
+			files: fstest.MapFS{
+				"mysql/first.up.sql":   {Data: []byte("CREATE TABLE a (id INTEGER);")},
+				"mysql/first.down.sql": {Data: []byte("DROP TABLE a;")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Renamed",
+			files: fstest.MapFS{
+				"mysql/0001_first.up.sql":   {Data: []byte("CREATE TABLE a (id INTEGER);")},
+				"mysql/0001_other.down.sql": {Data: []byte("DROP TABLE a;")},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &MigrationModel{Dialect: MySQL, Files: tt.files}
+
+			migrations, err := m.load()
+			assert.Equal(t, tt.wantErr, err != nil)
+			if tt.wantErr {
+				return
+			}
+
+			assert.Equal(t, 2, len(migrations))
+			assert.Equal(t, 1, migrations[0].Version)
+			assert.Equal(t, "first", migrations[0].Name)
+			assert.Equal(t, "DROP TABLE b;", migrations[1].Down)
+		})
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	stmts := splitStatements("CREATE TABLE a (\n    id INTEGER\n);\n\nCREATE INDEX idx ON a (id);\n")
+
+	assert.Equal(t, 2, len(stmts))
+	assert.Equal(t, "CREATE TABLE a (\n    id INTEGER\n)", stmts[0])
+	assert.Equal(t, "CREATE INDEX idx ON a (id)", stmts[1])
+}
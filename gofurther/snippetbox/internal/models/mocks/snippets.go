@@ -0,0 +1,331 @@
+package mocks
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ByChanderZap/snippetbox/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// SnippetStore keeps snippets in memory and follows the same rules as models.SnippetModel
+// (expiry, visibility, keyset pages, view limits) closely enough for handler tests
+type SnippetStore struct {
+	mu        sync.Mutex
+	snippets  []models.Snippet
+	revisions []models.Revision
+	// Now can be replaced to test expiry, it defaults to time.Now
+	Now func() time.Time
+}
+
+var _ models.SnippetStore = (*SnippetStore)(nil)
+
+// NewSnippetStore returns a store holding seed, snippets without an id or slug get one assigned
+func NewSnippetStore(seed ...models.Snippet) *SnippetStore {
+	m := &SnippetStore{}
+	for _, s := range seed {
+		m.add(s)
+	}
+	return m
+}
+
+func (m *SnippetStore) now() time.Time {
+	if m.Now != nil {
+		return m.Now().UTC()
+	}
+	return time.Now().UTC()
+}
+
+func (m *SnippetStore) add(s models.Snippet) models.Snippet {
+	if s.ID == 0 {
+		s.ID = len(m.snippets) + 1
+		if n := len(m.snippets); n > 0 {
+			s.ID = m.snippets[n-1].ID + 1
+		}
+	}
+	if s.Slug == "" {
+		s.Slug = "slug" + strconv.Itoa(s.ID)
+	}
+	if s.Created.IsZero() {
+		s.Created = m.now()
+	}
+	if s.Visibility == "" {
+		s.Visibility = models.VisibilityPublic
+	}
+	if s.Language == "" {
+		s.Language = "text"
+	}
+	m.snippets = append(m.snippets, s)
+	return s
+}
+
+func (m *SnippetStore) live(s models.Snippet) bool {
+	return s.Expires == nil || s.Expires.After(m.now())
+}
+
+// find returns the index of the first live snippet matching fn, or -1
+func (m *SnippetStore) find(fn func(models.Snippet) bool) int {
+	return slices.IndexFunc(m.snippets, func(s models.Snippet) bool {
+		return m.live(s) && fn(s)
+	})
+}
+
+func (m *SnippetStore) Insert(_ context.Context, params models.InsertSnippetParams) (int, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := m.add(models.Snippet{
+		Title:          params.Title,
+		Content:        params.Content,
+		Expires:        params.Expires,
+		UserID:         params.UserID,
+		Language:       params.Language,
+		Visibility:     params.Visibility,
+		ViewsLeft:      params.MaxViews,
+		HashedPassword: params.HashedPassword,
+		Encrypted:      params.Encrypted,
+		Tags:           slices.Clone(params.Tags),
+	})
+	return s.ID, s.Slug, nil
+}
+
+func (m *SnippetStore) Get(_ context.Context, id int) (models.Snippet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := m.find(func(s models.Snippet) bool { return s.ID == id })
+	if i < 0 {
+		return models.Snippet{}, models.ErrNoRecord
+	}
+	return m.snippets[i], nil
+}
+
+func (m *SnippetStore) GetBySlug(_ context.Context, slug string) (models.Snippet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := m.find(func(s models.Snippet) bool { return s.Slug == slug })
+	if i < 0 {
+		return models.Snippet{}, models.ErrNoRecord
+	}
+	return m.snippets[i], nil
+}
+
+func (m *SnippetStore) Page(_ context.Context, params models.PageParams) (models.SnippetPage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.page(func(models.Snippet) bool { return true }, params), nil
+}
+
+func (m *SnippetStore) Search(_ context.Context, params models.SearchParams) (models.SnippetPage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	terms := models.SearchTerms(params.Query)
+	if len(terms) == 0 {
+		return models.SnippetPage{}, nil
+	}
+
+	return m.page(func(s models.Snippet) bool {
+		if s.ViewsLeft > 0 || s.HashedPassword != nil || s.Encrypted {
+			return false
+		}
+		text := strings.ToLower(s.Title + " " + s.Content)
+		for _, t := range terms {
+			if !strings.Contains(text, strings.ToLower(t)) {
+				return false
+			}
+		}
+		return true
+	}, params.Page), nil
+}
+
+// page is the in-memory version of the keyset query of models.SnippetModel
+func (m *SnippetStore) page(filter func(models.Snippet) bool, params models.PageParams) models.SnippetPage {
+	var matches []models.Snippet
+	for _, s := range m.snippets {
+		if !m.live(s) || !filter(s) {
+			continue
+		}
+		if params.UserID != 0 && s.UserID != params.UserID {
+			continue
+		}
+		if params.UserID == 0 && s.Visibility != models.VisibilityPublic {
+			continue
+		}
+		if params.Tag != "" && !slices.Contains(s.Tags, params.Tag) {
+			continue
+		}
+		switch {
+		case params.Cursor != 0 && params.Backward && s.ID <= params.Cursor:
+			continue
+		case params.Cursor != 0 && !params.Backward && s.ID >= params.Cursor:
+			continue
+		}
+		matches = append(matches, s)
+	}
+
+	// newest first, or oldest first when walking backwards like the ORDER BY of the query
+	slices.SortFunc(matches, func(a, b models.Snippet) int {
+		if params.Backward {
+			return a.ID - b.ID
+		}
+		return b.ID - a.ID
+	})
+
+	hasMore := len(matches) > params.Limit
+	if hasMore {
+		matches = matches[:params.Limit]
+	}
+	if params.Backward {
+		slices.Reverse(matches)
+	}
+
+	page := models.SnippetPage{Snippets: matches}
+	if len(matches) == 0 {
+		return page
+	}
+	if hasMore || params.Backward {
+		page.NextCursor = matches[len(matches)-1].ID
+	}
+	if (hasMore && params.Backward) || (!params.Backward && params.Cursor != 0) {
+		page.PrevCursor = matches[0].ID
+	}
+	return page
+}
+
+func (m *SnippetStore) Update(_ context.Context, params models.UpdateSnippetParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.snippets, func(s models.Snippet) bool {
+		return s.ID == params.ID && s.UserID == params.UserID
+	})
+	if i < 0 {
+		return nil
+	}
+
+	s := &m.snippets[i]
+	if s.Title != params.Title || s.Content != params.Content {
+		m.revisions = append(m.revisions, models.Revision{
+			ID:        len(m.revisions) + 1,
+			SnippetID: s.ID,
+			Title:     s.Title,
+			Content:   s.Content,
+			Created:   m.now(),
+		})
+	}
+
+	s.Title = params.Title
+	s.Content = params.Content
+	s.Expires = params.Expires
+	s.ViewsLeft = params.MaxViews
+	s.Language = params.Language
+	s.Visibility = params.Visibility
+	s.HashedPassword = params.HashedPassword
+	s.Tags = slices.Clone(params.Tags)
+	return nil
+}
+
+func (m *SnippetStore) Delete(_ context.Context, params models.DeleteSnippetParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.snippets, func(s models.Snippet) bool {
+		return s.ID == params.ID && s.UserID == params.UserID
+	})
+	if i < 0 {
+		return models.ErrNoRecord
+	}
+	m.snippets = slices.Delete(m.snippets, i, i+1)
+	return nil
+}
+
+func (m *SnippetStore) Reveal(_ context.Context, params models.RevealParams) (models.Snippet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := m.find(func(s models.Snippet) bool { return s.Slug == params.Slug })
+	if i < 0 {
+		return models.Snippet{}, models.ErrNoRecord
+	}
+
+	s := m.snippets[i]
+	if s.ViewsLeft > 0 {
+		s.ViewsLeft--
+		if s.ViewsLeft == 0 {
+			m.snippets = slices.Delete(m.snippets, i, i+1)
+		} else {
+			m.snippets[i].ViewsLeft = s.ViewsLeft
+		}
+	}
+	return s, nil
+}
+
+func (m *SnippetStore) Unlock(_ context.Context, params models.UnlockSnippetParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := m.find(func(s models.Snippet) bool { return s.Slug == params.Slug })
+	if i < 0 {
+		return models.ErrNoRecord
+	}
+
+	hash := m.snippets[i].HashedPassword
+	if len(hash) == 0 {
+		return models.ErrInvalidCredentials
+	}
+
+	err := bcrypt.CompareHashAndPassword(hash, []byte(params.Password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return models.ErrInvalidCredentials
+	}
+	return err
+}
+
+func (m *SnippetStore) DeleteExpired(_ context.Context, params models.DeleteExpiredParams) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := 0
+	m.snippets = slices.DeleteFunc(m.snippets, func(s models.Snippet) bool {
+		if n < params.Limit && !m.live(s) {
+			n++
+			return true
+		}
+		return false
+	})
+	return n, nil
+}
+
+func (m *SnippetStore) Revisions(_ context.Context, params models.RevisionsParams) ([]models.Revision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var revisions []models.Revision
+	for _, rev := range slices.Backward(m.revisions) {
+		if rev.SnippetID == params.SnippetID {
+			revisions = append(revisions, rev)
+		}
+	}
+	return revisions, nil
+}
+
+func (m *SnippetStore) Revision(_ context.Context, params models.GetRevisionParams) (models.Revision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.revisions, func(rev models.Revision) bool {
+		return rev.ID == params.ID && rev.SnippetID == params.SnippetID
+	})
+	if i < 0 {
+		return models.Revision{}, models.ErrNoRecord
+	}
+	return m.revisions[i], nil
+}
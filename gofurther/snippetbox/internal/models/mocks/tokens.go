@@ -0,0 +1,76 @@
+package mocks
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ByChanderZap/snippetbox/internal/models"
+)
+
+type mockToken struct {
+	models.Token
+	value string
+}
+
+// TokenStore keeps tokens in memory, values are predictable ("token1", "token2"...) so tests can use them
+type TokenStore struct {
+	mu     sync.Mutex
+	tokens []mockToken
+	nextID int
+}
+
+var _ models.TokenStore = (*TokenStore)(nil)
+
+func (m *TokenStore) Insert(_ context.Context, params models.InsertTokenParams) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextID++
+	t := mockToken{
+		Token: models.Token{ID: m.nextID, UserID: params.UserID, Name: params.Name, Created: time.Now().UTC()},
+		value: "token" + strconv.Itoa(m.nextID),
+	}
+	m.tokens = append(m.tokens, t)
+	return t.value, nil
+}
+
+func (m *TokenStore) ForUser(_ context.Context, params models.TokensParams) ([]models.Token, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var tokens []models.Token
+	for _, t := range slices.Backward(m.tokens) {
+		if t.UserID == params.UserID {
+			tokens = append(tokens, t.Token)
+		}
+	}
+	return tokens, nil
+}
+
+func (m *TokenStore) Delete(_ context.Context, params models.DeleteTokenParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.tokens, func(t mockToken) bool {
+		return t.ID == params.ID && t.UserID == params.UserID
+	})
+	if i < 0 {
+		return models.ErrNoRecord
+	}
+	m.tokens = slices.Delete(m.tokens, i, i+1)
+	return nil
+}
+
+func (m *TokenStore) Authenticate(_ context.Context, params models.AuthenticateTokenParams) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.tokens, func(t mockToken) bool { return t.value == params.Token })
+	if i < 0 {
+		return 0, models.ErrInvalidCredentials
+	}
+	return m.tokens[i].UserID, nil
+}
@@ -0,0 +1,79 @@
+package mocks
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ByChanderZap/snippetbox/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// UserStore keeps users in memory, like UserModel it expects Insert to get an already hashed password
+type UserStore struct {
+	mu    sync.Mutex
+	users []models.User
+}
+
+var _ models.UserStore = (*UserStore)(nil)
+
+// NewUserStore returns a store holding seed, users without an id get one assigned.
+// HashedPassword of seeded users must be a bcrypt hash
+func NewUserStore(seed ...models.User) *UserStore {
+	m := &UserStore{}
+	for _, u := range seed {
+		m.add(u)
+	}
+	return m
+}
+
+func (m *UserStore) add(u models.User) {
+	if u.ID == 0 {
+		u.ID = len(m.users) + 1
+	}
+	if u.Created.IsZero() {
+		u.Created = time.Now().UTC()
+	}
+	m.users = append(m.users, u)
+}
+
+func (m *UserStore) Insert(_ context.Context, params models.InsertUserParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if slices.ContainsFunc(m.users, func(u models.User) bool { return u.Email == params.Email }) {
+		return models.ErrDuplicatedEmail
+	}
+
+	m.add(models.User{Name: params.Name, Email: params.Email, HashedPassword: []byte(params.Password)})
+	return nil
+}
+
+func (m *UserStore) Authenticate(_ context.Context, params models.AuthenticateUserParams) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.users, func(u models.User) bool { return u.Email == params.Email })
+	if i < 0 {
+		return 0, models.ErrInvalidCredentials
+	}
+
+	err := bcrypt.CompareHashAndPassword(m.users[i].HashedPassword, []byte(params.Password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return 0, models.ErrInvalidCredentials
+		}
+		return 0, err
+	}
+
+	return m.users[i].ID, nil
+}
+
+func (m *UserStore) Exists(_ context.Context, params models.ExistsParams) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return slices.ContainsFunc(m.users, func(u models.User) bool { return u.ID == params.ID }), nil
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Revision is an immutable copy of a snippet's title and content taken right before
+// it was edited, Created is the moment it got replaced
+type Revision struct {
+	ID        int
+	SnippetID int
+	Title     string
+	Content   string
+	Created   time.Time
+}
+
+// stmtInsertRevision keeps the current text when the update changes it, the %s are the
+// differs of title and content so a case or accent only edit still counts as a change
+const stmtInsertRevision = `
+	INSERT INTO snippet_revisions (snippet_id, title, content, created)
+	SELECT id, title, content, UTC_TIMESTAMP() FROM snippets
+	WHERE id = ? AND user_id = ? AND (%s OR %s)
+	`
+
+type RevisionsParams struct {
+	SnippetID int
+}
+
+const stmtGetRevisions = `
+	SELECT id, snippet_id, title, content, created FROM snippet_revisions
+	WHERE snippet_id = ?
+	ORDER BY id DESC
+	`
+
+// Revisions lists every previous version of a snippet, newest first
+func (m *SnippetModel) Revisions(ctx context.Context, params RevisionsParams) (_ []Revision, err error) {
+	ctx, done := withTimeout(ctx)
+	defer done(&err)
+
+	rows, err := m.DB.QueryContext(ctx, m.dialect().rebind(stmtGetRevisions), params.SnippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []Revision
+	for rows.Next() {
+		var rev Revision
+		err := rows.Scan(&rev.ID, &rev.SnippetID, &rev.Title, &rev.Content, &rev.Created)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+type GetRevisionParams struct {
+	ID        int
+	SnippetID int
+}
+
+const stmtGetRevision = `
+	SELECT id, snippet_id, title, content, created FROM snippet_revisions
+	WHERE id = ? AND snippet_id = ?
+	`
+
+func (m *SnippetModel) Revision(ctx context.Context, params GetRevisionParams) (_ Revision, err error) {
+	ctx, done := withTimeout(ctx)
+	defer done(&err)
+
+	var rev Revision
+
+	err = m.DB.QueryRowContext(ctx, m.dialect().rebind(stmtGetRevision), params.ID, params.SnippetID).Scan(
+		&rev.ID,
+		&rev.SnippetID,
+		&rev.Title,
+		&rev.Content,
+		&rev.Created,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Revision{}, ErrNoRecord
+		}
+		return Revision{}, err
+	}
+
+	return rev, nil
+}
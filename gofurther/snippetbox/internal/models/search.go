@@ -0,0 +1,52 @@
+package models
+
+import (
+	"context"
+	"strings"
+)
+
+type SearchParams struct {
+	Query string
+	Page  PageParams
+}
+
+// SearchTerms splits the query in words, dropping the characters MySQL treats as
+// boolean operators so user input can't change the meaning of the search
+func SearchTerms(query string) []string {
+	clean := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`+-<>()~*"@`, r) {
+			return ' '
+		}
+		return r
+	}, query)
+
+	return strings.Fields(clean)
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Search returns a page of snippets whose title or content contain every word in the query.
+// with FullText enabled it goes through the full text index on (title, content) of the dialect, otherwise
+// (or when there is none) it falls back to LIKE which is fine for small tables and needs no index at all.
+// results are still ordered by id so pagination keeps working the same way it does on the home page
+func (m *SnippetModel) Search(ctx context.Context, params SearchParams) (_ SnippetPage, err error) {
+	ctx, done := withTimeout(ctx)
+	defer done(&err)
+
+	terms := SearchTerms(params.Query)
+	if len(terms) == 0 {
+		return SnippetPage{}, nil
+	}
+
+	// results show an excerpt of the content, which would leak view limited and password protected
+	// snippets, encrypted ones are skipped too since matching against ciphertext is meaningless
+	filter := ` AND s.views_left IS NULL AND s.hashed_password IS NULL AND NOT s.encrypted`
+
+	d := m.dialect()
+	match, args, ok := d.fullText(terms)
+	if !m.FullText || !ok {
+		match, args = likeTerms(d, terms)
+	}
+
+	return m.page(ctx, filter+match, args, params.Page)
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"crypto/rand"
+)
+
+const (
+	slugAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	slugLength   = 10
+)
+
+// newSlug returns a random base62 string, 10 characters give us ~59 bits which is
+// plenty to make guessing an unlisted snippet impractical
+func newSlug() (string, error) {
+	slug := make([]byte, 0, slugLength)
+	buf := make([]byte, slugLength*2)
+
+	for len(slug) < slugLength {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+
+		for _, b := range buf {
+			// 248 is the biggest multiple of 62 that fits in a byte, anything above
+			// it is thrown away so every character is equally likely
+			if b >= 248 {
+				continue
+			}
+			slug = append(slug, slugAlphabet[b%62])
+			if len(slug) == slugLength {
+				break
+			}
+		}
+	}
+
+	return string(slug), nil
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ByChanderZap/snippetbox/internal/assert"
+)
+
+func TestNewSlug(t *testing.T) {
+	seen := map[string]bool{}
+
+	for range 100 {
+		slug, err := newSlug()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, slugLength, len(slug))
+		for _, c := range slug {
+			assert.Equal(t, true, strings.ContainsRune(slugAlphabet, c))
+		}
+
+		assert.Equal(t, false, seen[slug])
+		seen[slug] = true
+	}
+}
@@ -1,67 +1,225 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Visibility decides who can see a snippet, only public ones are listed on the home,
+// tag and search pages, unlisted ones can still be opened by anyone with the link and
+// private ones are only visible to their owner
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
 )
 
 type Snippet struct {
 	ID      int
+	Slug    string
 	Title   string
 	Content string
 	Created time.Time
-	Expires time.Time
+	// Expires is nil for snippets that never expire
+	Expires    *time.Time
+	UserID     int
+	UserName   string
+	Tags       []string
+	Language   string
+	Visibility string
+	// ViewsLeft is how many more times the snippet can be revealed before it is deleted,
+	// 0 means there is no limit
+	ViewsLeft int
+	// HashedPassword is the bcrypt hash protecting the content, nil when the snippet is open
+	HashedPassword []byte
+	// Encrypted snippets hold ciphertext produced in the browser, the key never reaches us
+	Encrypted bool
 }
 
 type SnippetModel struct {
 	DB *sql.DB
+	// Dialect defaults to MySQL
+	Dialect Dialect
+	// FullText makes Search use the full text index on (title, content) when the dialect has one
+	FullText bool
+}
+
+func (m *SnippetModel) dialect() Dialect {
+	return dialectOr(m.Dialect)
+}
+
+// every select joins the owner so templates can show who wrote the snippet,
+// keep the column order in sync with scanSnippet
+const snippetColumns = `s.id, s.slug, s.title, s.content, s.created, s.expires, s.user_id, u.name, s.language, s.visibility,
+	COALESCE(s.views_left, 0), s.hashed_password, s.encrypted,
+	(SELECT GROUP_CONCAT(t.name ORDER BY t.name) FROM snippet_tags st
+		INNER JOIN tags t ON t.id = st.tag_id WHERE st.snippet_id = s.id)`
+
+// notExpired is the condition every read shares, a NULL expires means the snippet never expires
+const notExpired = `(s.expires IS NULL OR s.expires > UTC_TIMESTAMP())`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSnippet(row rowScanner) (Snippet, error) {
+	var s Snippet
+	var tags sql.NullString
+	err := row.Scan(
+		&s.ID,
+		&s.Slug,
+		&s.Title,
+		&s.Content,
+		&s.Created,
+		&s.Expires,
+		&s.UserID,
+		&s.UserName,
+		&s.Language,
+		&s.Visibility,
+		&s.ViewsLeft,
+		&s.HashedPassword,
+		&s.Encrypted,
+		&tags,
+	)
+	if err != nil {
+		return Snippet{}, err
+	}
+
+	// tag names can't contain commas so splitting the GROUP_CONCAT is safe
+	if tags.Valid {
+		s.Tags = strings.Split(tags.String, ",")
+	}
+
+	return s, nil
+}
+
+func scanSnippets(rows *sql.Rows) ([]Snippet, error) {
+	defer rows.Close()
+
+	var snippets []Snippet
+	for rows.Next() {
+		s, err := scanSnippet(rows)
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
 }
 
 const stmt = `
-	INSERT INTO snippets (title, content, created, expires)
-	VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))
+	INSERT INTO snippets (slug, title, content, created, expires, user_id, language, visibility, views_left, hashed_password, encrypted)
+	VALUES(?, ?, ?, UTC_TIMESTAMP(), ?, ?, ?, ?, NULLIF(?, 0), ?, ?)
 	`
 
+// Expires is the UTC time the snippet stops being served, nil keeps it forever. MaxViews
+// adds a second, view based, expiry on top of it: the snippet is deleted once it has been
+// revealed that many times, 0 disables it
 type InsertSnippetParams struct {
-	Title   string
-	Content string
-	Expires int
+	Title      string
+	Content    string
+	Expires    *time.Time
+	MaxViews   int
+	UserID     int
+	Language   string
+	Visibility string
+	// HashedPassword must already be a bcrypt hash, leave it nil for an open snippet
+	HashedPassword []byte
+	Encrypted      bool
+	// Tags are stored in the same transaction, normalized and deduplicated like for TagModel.Set
+	Tags []string
+}
+
+// insertSlugAttempts is how many fresh slugs Insert tries before giving up on collisions,
+// with 62^10 possible slugs even a second attempt should basically never happen
+const insertSlugAttempts = 3
+
+// Insert stores a new snippet under a random slug and returns both its id and slug
+func (m *SnippetModel) Insert(ctx context.Context, params InsertSnippetParams) (_ int, _ string, err error) {
+	ctx, done := withTimeout(ctx)
+	defer done(&err)
+
+	d := m.dialect()
+
+	for attempt := 1; ; attempt++ {
+		slug, err := newSlug()
+		if err != nil {
+			return 0, "", err
+		}
+
+		id, err := m.insert(ctx, slug, params)
+		if err != nil {
+			if attempt < insertSlugAttempts && d.isUniqueViolation(err, uniqueSnippetSlug) {
+				continue
+			}
+			return 0, "", err
+		}
+
+		return id, slug, nil
+	}
 }
 
-func (m *SnippetModel) Insert(params InsertSnippetParams) (int, error) {
-	result, err := m.DB.Exec(
-		stmt,
+// insert is one attempt of Insert, every attempt gets its own transaction since Postgres
+// refuses any statement after one failed on a slug collision
+func (m *SnippetModel) insert(ctx context.Context, slug string, params InsertSnippetParams) (int, error) {
+	d := m.dialect()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	id, err := d.insertID(
+		ctx,
+		tx,
+		d.rebind(stmt),
+		slug,
 		params.Title,
 		params.Content,
 		params.Expires,
+		params.UserID,
+		params.Language,
+		params.Visibility,
+		params.MaxViews,
+		params.HashedPassword,
+		params.Encrypted,
 	)
 	if err != nil {
 		return 0, err
 	}
 
-	id, err := result.LastInsertId()
+	err = setTags(ctx, tx, d, int(id), params.Tags)
 	if err != nil {
 		return 0, err
 	}
-	return int(id), nil
+
+	return int(id), tx.Commit()
 }
 
 const stmtGet = `
-	SELECT id, title, content, created, expires FROM snippets
-	WHERE expires > UTC_TIMESTAMP() AND id = ?
+	SELECT ` + snippetColumns + ` FROM snippets s
+	INNER JOIN users u ON u.id = s.user_id
+	WHERE ` + notExpired + ` AND s.id = ?
 	`
 
-func (m *SnippetModel) Get(id int) (Snippet, error) {
-	var s Snippet
+func (m *SnippetModel) Get(ctx context.Context, id int) (_ Snippet, err error) {
+	ctx, done := withTimeout(ctx)
+	defer done(&err)
 
-	err := m.DB.QueryRow(stmtGet, id).Scan(
-		&s.ID,
-		&s.Title,
-		&s.Content,
-		&s.Created,
-		&s.Expires,
-	)
+	s, err := scanSnippet(m.DB.QueryRowContext(ctx, m.dialect().rebind(stmtGet), id))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return Snippet{}, ErrNoRecord
@@ -72,41 +230,368 @@ func (m *SnippetModel) Get(id int) (Snippet, error) {
 	return s, nil
 }
 
-const stmtGetLastTen = `
-SELECT id, title, content, created, expires FROM snippets
-	WHERE expires > UTC_TIMESTAMP() ORDER BY id DESC LIMIT 10
+const stmtGetBySlug = `
+	SELECT ` + snippetColumns + ` FROM snippets s
+	INNER JOIN users u ON u.id = s.user_id
+	WHERE ` + notExpired + ` AND s.slug = ?
 	`
 
-func (m *SnippetModel) Latest() ([]Snippet, error) {
-	var snippets []Snippet
+func (m *SnippetModel) GetBySlug(ctx context.Context, slug string) (_ Snippet, err error) {
+	ctx, done := withTimeout(ctx)
+	defer done(&err)
 
-	rows, err := m.DB.Query(stmtGetLastTen)
+	s, err := scanSnippet(m.DB.QueryRowContext(ctx, m.dialect().rebind(stmtGetBySlug), slug))
 	if err != nil {
-		return nil, err
+		if errors.Is(err, sql.ErrNoRows) {
+			return Snippet{}, ErrNoRecord
+		}
+		return Snippet{}, err
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var s Snippet
-		err := rows.Scan(
-			&s.ID,
-			&s.Title,
-			&s.Content,
-			&s.Created,
-			&s.Expires,
-		)
+	return s, nil
+}
+
+// PageParams describes a keyset page over the snippets ordered newest first.
+// Cursor is the id the page starts after (0 means the very first page) and Backward
+// walks towards newer snippets, which is what a "previous page" link needs.
+// UserID and Tag narrow the listing to a single owner or tag when they are set.
+// only public snippets are returned unless UserID is set, owners get to see all of theirs
+type PageParams struct {
+	Cursor   int
+	Backward bool
+	Limit    int
+	UserID   int
+	Tag      string
+}
+
+// SnippetPage holds one page of snippets, NextCursor and PrevCursor are 0 when
+// there is nothing more to show in that direction
+type SnippetPage struct {
+	Snippets   []Snippet
+	NextCursor int
+	PrevCursor int
+}
+
+const stmtPageBase = `
+	SELECT ` + snippetColumns + ` FROM snippets s
+	INNER JOIN users u ON u.id = s.user_id
+	WHERE ` + notExpired
+
+// Page returns a page of non expired snippets. it filters on id instead of using
+// OFFSET so deep pages cost the same as the first one
+func (m *SnippetModel) Page(ctx context.Context, params PageParams) (_ SnippetPage, err error) {
+	ctx, done := withTimeout(ctx)
+	defer done(&err)
+
+	return m.page(ctx, "", nil, params)
+}
+
+// page runs the keyset query shared by every listing, filter is an extra
+// "AND ..." condition with its own placeholders in filterArgs
+func (m *SnippetModel) page(ctx context.Context, filter string, filterArgs []any, params PageParams) (SnippetPage, error) {
+	query := stmtPageBase + filter
+	args := slices.Clone(filterArgs)
+
+	if params.UserID != 0 {
+		query += ` AND s.user_id = ?`
+		args = append(args, params.UserID)
+	} else {
+		query += ` AND s.visibility = ?`
+		args = append(args, VisibilityPublic)
+	}
+
+	if params.Tag != "" {
+		query += ` AND EXISTS (SELECT 1 FROM snippet_tags st INNER JOIN tags t ON t.id = st.tag_id
+			WHERE st.snippet_id = s.id AND t.name = ?)`
+		args = append(args, params.Tag)
+	}
+
+	switch {
+	case params.Cursor != 0 && params.Backward:
+		query += ` AND s.id > ? ORDER BY s.id ASC`
+		args = append(args, params.Cursor)
+	case params.Cursor != 0:
+		query += ` AND s.id < ? ORDER BY s.id DESC`
+		args = append(args, params.Cursor)
+	default:
+		query += ` ORDER BY s.id DESC`
+	}
+
+	// one extra row tells us whether there is another page without a COUNT(*)
+	query += ` LIMIT ?`
+	args = append(args, params.Limit+1)
+
+	rows, err := m.DB.QueryContext(ctx, m.dialect().rebind(query), args...)
+	if err != nil {
+		return SnippetPage{}, err
+	}
+
+	snippets, err := scanSnippets(rows)
+	if err != nil {
+		return SnippetPage{}, err
+	}
+
+	hasMore := len(snippets) > params.Limit
+	if hasMore {
+		snippets = snippets[:params.Limit]
+	}
+
+	if params.Backward {
+		slices.Reverse(snippets)
+	}
+
+	page := SnippetPage{Snippets: snippets}
+	if len(snippets) == 0 {
+		return page, nil
+	}
+
+	// walking backwards there is always a next page (the one we came from), walking
+	// forwards there is always a previous one unless this is the first page
+	if hasMore || params.Backward {
+		page.NextCursor = snippets[len(snippets)-1].ID
+	}
+	if (hasMore && params.Backward) || (!params.Backward && params.Cursor != 0) {
+		page.PrevCursor = snippets[0].ID
+	}
+
+	return page, nil
+}
+
+type UpdateSnippetParams struct {
+	ID         int
+	UserID     int
+	Title      string
+	Content    string
+	Expires    *time.Time
+	MaxViews   int
+	Language   string
+	Visibility string
+	// HashedPassword replaces the current one, pass the existing hash to keep it
+	HashedPassword []byte
+	// Tags replace the current ones, nil removes them all
+	Tags []string
+}
+
+const stmtLockOwned = `SELECT id FROM snippets WHERE id = ? AND user_id = ?`
+
+const stmtUpdate = `
+	UPDATE snippets
+	SET title = ?, content = ?, expires = ?, language = ?, visibility = ?,
+		views_left = NULLIF(?, 0), hashed_password = ?
+	WHERE id = ? AND user_id = ?
+	`
+
+// Update only touches the snippet when it belongs to params.UserID, ownership is still
+// checked by the handlers but this way a bug up there can't leak into someone else's data.
+// the previous title and content are saved as a revision and the tags replaced in the same transaction
+func (m *SnippetModel) Update(ctx context.Context, params UpdateSnippetParams) (err error) {
+	ctx, done := withTimeout(ctx)
+	defer done(&err)
+
+	d := m.dialect()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// snippet_tags has no user_id to filter on, so ownership is checked once up front
+	var id int
+	err = tx.QueryRowContext(ctx, d.rebind(stmtLockOwned+d.forUpdate()), params.ID, params.UserID).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	// the revision is skipped when neither title nor content changed, e.g. only the expiry was bumped
+	_, err = tx.ExecContext(ctx, d.rebind(fmt.Sprintf(stmtInsertRevision, d.differs("title"), d.differs("content"))), params.ID, params.UserID, params.Title, params.Content)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		d.rebind(stmtUpdate),
+		params.Title,
+		params.Content,
+		params.Expires,
+		params.Language,
+		params.Visibility,
+		params.MaxViews,
+		params.HashedPassword,
+		params.ID,
+		params.UserID,
+	)
+	if err != nil {
+		return err
+	}
+
+	err = setTags(ctx, tx, d, params.ID, params.Tags)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+type DeleteSnippetParams struct {
+	ID     int
+	UserID int
+}
+
+const stmtDelete = `DELETE FROM snippets WHERE id = ? AND user_id = ?`
+
+func (m *SnippetModel) Delete(ctx context.Context, params DeleteSnippetParams) (err error) {
+	ctx, done := withTimeout(ctx)
+	defer done(&err)
+
+	result, err := m.DB.ExecContext(ctx, m.dialect().rebind(stmtDelete), params.ID, params.UserID)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+const stmtLockViews = `
+	SELECT views_left FROM snippets
+	WHERE slug = ? AND (expires IS NULL OR expires > UTC_TIMESTAMP())
+	`
+
+const stmtDecrementViews = `UPDATE snippets SET views_left = views_left - 1 WHERE id = ?`
+
+const stmtDeleteByID = `DELETE FROM snippets WHERE id = ?`
+
+type RevealParams struct {
+	Slug string
+}
+
+// Reveal returns the snippet using up one of its views, when that was the last one the
+// snippet is deleted before returning. the row stays locked for the whole transaction so
+// two people opening a burn after reading link at the same time can't both get the content.
+// for view limited snippets the returned ViewsLeft is what remains after this view, so 0
+// means the snippet is gone
+func (m *SnippetModel) Reveal(ctx context.Context, params RevealParams) (_ Snippet, err error) {
+	ctx, done := withTimeout(ctx)
+	defer done(&err)
+
+	d := m.dialect()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Snippet{}, err
+	}
+	defer tx.Rollback()
+
+	var viewsLeft sql.NullInt64
+	err = tx.QueryRowContext(ctx, d.rebind(stmtLockViews+d.forUpdate()), params.Slug).Scan(&viewsLeft)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Snippet{}, ErrNoRecord
+		}
+		return Snippet{}, err
+	}
+
+	s, err := scanSnippet(tx.QueryRowContext(ctx, d.rebind(stmtGetBySlug), params.Slug))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Snippet{}, ErrNoRecord
+		}
+		return Snippet{}, err
+	}
+
+	if viewsLeft.Valid {
+		if viewsLeft.Int64 > 1 {
+			_, err = tx.ExecContext(ctx, d.rebind(stmtDecrementViews), s.ID)
+		} else {
+			_, err = tx.ExecContext(ctx, d.rebind(stmtDeleteByID), s.ID)
+		}
 		if err != nil {
-			if errors.Is(err, sql.ErrNoRows) {
-				return nil, ErrNoRecord
-			}
-			return nil, err
+			return Snippet{}, err
 		}
-		snippets = append(snippets, s)
+		s.ViewsLeft = int(max(viewsLeft.Int64-1, 0))
 	}
 
-	if err = rows.Err(); err != nil {
-		return nil, err
+	if err = tx.Commit(); err != nil {
+		return Snippet{}, err
 	}
 
-	return snippets, nil
+	return s, nil
+}
+
+type UnlockSnippetParams struct {
+	Slug     string
+	Password string
+}
+
+const stmtGetSnippetPassword = `
+	SELECT hashed_password FROM snippets
+	WHERE slug = ? AND (expires IS NULL OR expires > UTC_TIMESTAMP())
+	`
+
+// Unlock checks the password of a protected snippet the same way Authenticate checks
+// a user's, a wrong password (or an unprotected snippet) gives ErrInvalidCredentials
+func (m *SnippetModel) Unlock(ctx context.Context, params UnlockSnippetParams) (err error) {
+	ctx, done := withTimeout(ctx)
+	defer done(&err)
+
+	var hashedPassword []byte
+
+	err = m.DB.QueryRowContext(ctx, m.dialect().rebind(stmtGetSnippetPassword), params.Slug).Scan(&hashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+
+	if len(hashedPassword) == 0 {
+		return ErrInvalidCredentials
+	}
+
+	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(params.Password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrInvalidCredentials
+		}
+		return err
+	}
+
+	return nil
+}
+
+type DeleteExpiredParams struct {
+	Limit int
+}
+
+// DeleteExpired removes up to params.Limit expired snippets and returns how many were deleted,
+// keeping each call small avoids holding locks on the table for long
+func (m *SnippetModel) DeleteExpired(ctx context.Context, params DeleteExpiredParams) (_ int, err error) {
+	ctx, done := withTimeout(ctx)
+	defer done(&err)
+
+	d := m.dialect()
+
+	result, err := m.DB.ExecContext(ctx, d.rebind(d.deleteExpired()), params.Limit)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
 }
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"context"
+)
+
+// the handlers only talk to the database through these interfaces, the *Model types are the
+// SQL implementations (for every Dialect) and internal/models/mocks has in-memory ones for tests.
+// every method takes the context of the request so queries stop when the client is gone
+
+type SnippetStore interface {
+	Insert(ctx context.Context, params InsertSnippetParams) (int, string, error)
+	Get(ctx context.Context, id int) (Snippet, error)
+	GetBySlug(ctx context.Context, slug string) (Snippet, error)
+	Page(ctx context.Context, params PageParams) (SnippetPage, error)
+	Search(ctx context.Context, params SearchParams) (SnippetPage, error)
+	Update(ctx context.Context, params UpdateSnippetParams) error
+	Delete(ctx context.Context, params DeleteSnippetParams) error
+	Reveal(ctx context.Context, params RevealParams) (Snippet, error)
+	Unlock(ctx context.Context, params UnlockSnippetParams) error
+	DeleteExpired(ctx context.Context, params DeleteExpiredParams) (int, error)
+	Revisions(ctx context.Context, params RevisionsParams) ([]Revision, error)
+	Revision(ctx context.Context, params GetRevisionParams) (Revision, error)
+}
+
+type UserStore interface {
+	Insert(ctx context.Context, params InsertUserParams) error
+	Authenticate(ctx context.Context, params AuthenticateUserParams) (int, error)
+	Exists(ctx context.Context, params ExistsParams) (bool, error)
+}
+
+type TagStore interface {
+	Set(ctx context.Context, params SetTagsParams) error
+}
+
+type TokenStore interface {
+	Insert(ctx context.Context, params InsertTokenParams) (string, error)
+	ForUser(ctx context.Context, params TokensParams) ([]Token, error)
+	Delete(ctx context.Context, params DeleteTokenParams) error
+	Authenticate(ctx context.Context, params AuthenticateTokenParams) (int, error)
+}
+
+var (
+	_ SnippetStore = (*SnippetModel)(nil)
+	_ UserStore    = (*UserModel)(nil)
+	_ TagStore     = (*TagModel)(nil)
+	_ TokenStore   = (*TokenModel)(nil)
+)
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+)
+
+// TagModel manages the many to many link between snippets and tags, tags live in
+// their own table so renaming or listing them never has to touch snippets
+type TagModel struct {
+	DB *sql.DB
+	// Dialect defaults to MySQL
+	Dialect Dialect
+}
+
+const stmtClearSnippetTags = `DELETE FROM snippet_tags WHERE snippet_id = ?`
+
+const stmtInsertSnippetTag = `INSERT INTO snippet_tags (snippet_id, tag_id) VALUES (?, ?)`
+
+type SetTagsParams struct {
+	SnippetID int
+	Tags      []string
+}
+
+// Set replaces every tag of a snippet with params.Tags, the tags are expected to be
+// already normalized and deduplicated. snippets get their tags through InsertSnippetParams
+// and UpdateSnippetParams, this is for changing the tags alone
+func (m *TagModel) Set(ctx context.Context, params SetTagsParams) (err error) {
+	ctx, done := withTimeout(ctx)
+	defer done(&err)
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = setTags(ctx, tx, dialectOr(m.Dialect), params.SnippetID, params.Tags)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// setTags does the work of Set inside the transaction of the caller, so a snippet and
+// its tags are written together
+func setTags(ctx context.Context, tx execQuerier, d Dialect, snippetID int, tags []string) error {
+	_, err := tx.ExecContext(ctx, d.rebind(stmtClearSnippetTags), snippetID)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range tags {
+		tagID, err := d.insertID(ctx, tx, d.rebind(d.upsertTag()), name)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, d.rebind(stmtInsertSnippetTag), snippetID, tagID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
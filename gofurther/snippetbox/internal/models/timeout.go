@@ -0,0 +1,27 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// QueryTimeout is the longest a single model call can take, a caller's context with an
+// earlier deadline (or one cancelled because the client went away) stops it sooner
+const QueryTimeout = 5 * time.Second
+
+// withTimeout bounds ctx by QueryTimeout for one model call. done has to be deferred with a
+// pointer to the error the call returns, it releases the timer and turns an error caused by
+// the deadline into ErrTimeout so callers can tell it apart
+func withTimeout(ctx context.Context) (context.Context, func(*error)) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+
+	return ctx, func(err *error) {
+		// drivers don't all wrap the context error (SQLite reports an interrupt) so ask ctx instead
+		if *err != nil && !errors.Is(*err, ErrTimeout) && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			*err = fmt.Errorf("%w: %w", ErrTimeout, *err)
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ByChanderZap/snippetbox/internal/assert"
+)
+
+func TestQueryTimeout(t *testing.T) {
+	db, d := newTestDB(t)
+	snippets := &SnippetModel{DB: db, Dialect: d}
+
+	expired, cancel := context.WithDeadline(t.Context(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	cancelled, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		wantTimeout bool
+	}{
+		{
+			name:        "Deadline exceeded",
+			ctx:         expired,
+			wantTimeout: true,
+		},
+		{
+			name:        "Client gone",
+			ctx:         cancelled,
+			wantTimeout: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := snippets.Page(tt.ctx, PageParams{Limit: 10})
+
+			assert.Equal(t, true, err != nil)
+			assert.Equal(t, tt.wantTimeout, errors.Is(err, ErrTimeout))
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"time"
+)
+
+// Token is what we show on the settings page, the value itself is only known when it is created
+type Token struct {
+	ID      int
+	UserID  int
+	Name    string
+	Created time.Time
+}
+
+// TokenModel backs the personal tokens used by clients that can't keep a session cookie around,
+// only the sha256 of a token is stored so a leaked table can't be used to log in
+type TokenModel struct {
+	DB *sql.DB
+	// Dialect defaults to MySQL
+	Dialect Dialect
+}
+
+// tokenPrefix makes tokens easy to spot in scripts and secret scanners
+const tokenPrefix = "sbx_"
+
+// newToken returns 32 random bytes, way more than enough for something that can't be brute forced online
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return tokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken is all we need here, tokens are long random strings so there is nothing to brute force
+// and a fast hash lets us look them up by value
+func hashToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
+
+type InsertTokenParams struct {
+	UserID int
+	Name   string
+}
+
+const stmtInsertToken = `
+	INSERT INTO tokens (user_id, name, hash, created)
+	VALUES(?, ?, ?, UTC_TIMESTAMP())
+	`
+
+// Insert creates a token and returns its plain text value, it is the only time it can be read
+func (m *TokenModel) Insert(ctx context.Context, params InsertTokenParams) (_ string, err error) {
+	ctx, done := withTimeout(ctx)
+	defer done(&err)
+
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = m.DB.ExecContext(ctx, dialectOr(m.Dialect).rebind(stmtInsertToken), params.UserID, params.Name, hashToken(token))
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+type TokensParams struct {
+	UserID int
+}
+
+const stmtUserTokens = `
+	SELECT id, user_id, name, created
+	FROM tokens
+	WHERE user_id = ?
+	ORDER BY id DESC
+	`
+
+func (m *TokenModel) ForUser(ctx context.Context, params TokensParams) (_ []Token, err error) {
+	ctx, done := withTimeout(ctx)
+	defer done(&err)
+
+	rows, err := m.DB.QueryContext(ctx, dialectOr(m.Dialect).rebind(stmtUserTokens), params.UserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []Token
+	for rows.Next() {
+		var t Token
+		err = rows.Scan(&t.ID, &t.UserID, &t.Name, &t.Created)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+type DeleteTokenParams struct {
+	ID     int
+	UserID int
+}
+
+const stmtDeleteToken = `DELETE FROM tokens WHERE id = ? AND user_id = ?`
+
+// Delete revokes a token, the user id is part of the filter so nobody can revoke someone else's
+func (m *TokenModel) Delete(ctx context.Context, params DeleteTokenParams) (err error) {
+	ctx, done := withTimeout(ctx)
+	defer done(&err)
+
+	result, err := m.DB.ExecContext(ctx, dialectOr(m.Dialect).rebind(stmtDeleteToken), params.ID, params.UserID)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+type AuthenticateTokenParams struct {
+	Token string
+}
+
+const stmtAuthenticateToken = `
+	SELECT user_id
+	FROM tokens
+	WHERE hash = ?
+	`
+
+// Authenticate returns the id of the user that owns the token
+func (m *TokenModel) Authenticate(ctx context.Context, params AuthenticateTokenParams) (_ int, err error) {
+	ctx, done := withTimeout(ctx)
+	defer done(&err)
+
+	var userID int
+
+	err = m.DB.QueryRowContext(ctx, dialectOr(m.Dialect).rebind(stmtAuthenticateToken), hashToken(params.Token)).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrInvalidCredentials
+		}
+		return 0, err
+	}
+
+	return userID, nil
+}
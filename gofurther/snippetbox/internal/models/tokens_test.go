@@ -0,0 +1,34 @@
+package models
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ByChanderZap/snippetbox/internal/assert"
+)
+
+func TestNewToken(t *testing.T) {
+	seen := map[string]bool{}
+
+	for range 100 {
+		token, err := newToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, true, strings.HasPrefix(token, tokenPrefix))
+		assert.Equal(t, len(tokenPrefix)+43, len(token))
+
+		assert.Equal(t, false, seen[token])
+		seen[token] = true
+	}
+}
+
+func TestHashToken(t *testing.T) {
+	a := hashToken("sbx_first")
+
+	assert.Equal(t, 32, len(a))
+	assert.Equal(t, true, bytes.Equal(a, hashToken("sbx_first")))
+	assert.Equal(t, false, bytes.Equal(a, hashToken("sbx_second")))
+}
@@ -1,12 +1,11 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"errors"
-	"strings"
 	"time"
 
-	"github.com/go-sql-driver/mysql"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -20,6 +19,8 @@ type User struct {
 
 type UserModel struct {
 	DB *sql.DB
+	// Dialect defaults to MySQL
+	Dialect Dialect
 }
 
 type InsertUserParams struct {
@@ -28,24 +29,26 @@ type InsertUserParams struct {
 	Password string
 }
 
-func (m *UserModel) Insert(params InsertUserParams) error {
+func (m *UserModel) Insert(ctx context.Context, params InsertUserParams) (err error) {
+	ctx, done := withTimeout(ctx)
+	defer done(&err)
+
 	const stmt = `
 	INSERT INTO users (name, email, hashed_password, created)
 	VALUES(?, ?, ?, UTC_TIMESTAMP())
 	`
-	_, err := m.DB.Exec(
-		stmt,
+	d := dialectOr(m.Dialect)
+
+	_, err = m.DB.ExecContext(
+		ctx,
+		d.rebind(stmt),
 		params.Name,
 		params.Email,
 		params.Password,
 	)
 	if err != nil {
-		var mySQLError *mysql.MySQLError
-
-		if errors.As(err, &mySQLError) {
-			if mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "users_uc_email") {
-				return ErrDuplicatedEmail
-			}
+		if d.isUniqueViolation(err, uniqueUserEmail) {
+			return ErrDuplicatedEmail
 		}
 
 		return err
@@ -65,11 +68,14 @@ const stmtAuthenticateQuery = `
 	WHERE email = ?
 	`
 
-func (m *UserModel) Authenticate(params AuthenticateUserParams) (int, error) {
+func (m *UserModel) Authenticate(ctx context.Context, params AuthenticateUserParams) (_ int, err error) {
+	ctx, done := withTimeout(ctx)
+	defer done(&err)
+
 	var id int
 	var hashedPassword []byte
 
-	err := m.DB.QueryRow(stmtAuthenticateQuery, params.Email).Scan(
+	err = m.DB.QueryRowContext(ctx, dialectOr(m.Dialect).rebind(stmtAuthenticateQuery), params.Email).Scan(
 		&id,
 		&hashedPassword,
 	)
@@ -95,11 +101,14 @@ type ExistsParams struct {
 	ID int
 }
 
-func (m *UserModel) Exists(params ExistsParams) (bool, error) {
+func (m *UserModel) Exists(ctx context.Context, params ExistsParams) (_ bool, err error) {
+	ctx, done := withTimeout(ctx)
+	defer done(&err)
+
 	stmt := `SELECT true from users WHERE id = ?`
 	var exists bool
 
-	err := m.DB.QueryRow(stmt, params.ID).Scan(&exists)
+	err = m.DB.QueryRowContext(ctx, dialectOr(m.Dialect).rebind(stmt), params.ID).Scan(&exists)
 	if err != nil {
 		return false, err
 	}
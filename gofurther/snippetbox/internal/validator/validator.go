@@ -58,3 +58,19 @@ var EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+/=?^_`{|}~-]+@[a-zA-Z0-9](
 func ValidEmail(email string, rx *regexp.Regexp) bool {
 	return rx.MatchString(email)
 }
+
+func MaxItems[T any](values []T, n int) bool {
+	return len(values) <= n
+}
+
+// TagRX allows short lowercase words plus the few symbols languages need, like c++ or c#
+var TagRX = regexp.MustCompile(`^[a-z0-9][a-z0-9+#._-]{0,29}$`)
+
+func AllMatch(values []string, rx *regexp.Regexp) bool {
+	for _, v := range values {
+		if !rx.MatchString(v) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,10 @@
+package migrations
+
+import (
+	"embed"
+)
+
+// one directory per -db-driver, holding <version>_<name>.up.sql and .down.sql pairs
+//
+//go:embed "mysql" "postgres" "sqlite"
+var Files embed.FS
@@ -0,0 +1,20 @@
+CREATE TABLE snippets (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    slug CHAR(10) COLLATE utf8mb4_bin NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    content MEDIUMTEXT NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME,
+    user_id INTEGER NOT NULL,
+    language VARCHAR(20) NOT NULL DEFAULT 'text',
+    visibility VARCHAR(10) NOT NULL DEFAULT 'public',
+    views_left INTEGER,
+    hashed_password CHAR(60),
+    encrypted BOOLEAN NOT NULL DEFAULT FALSE,
+    CONSTRAINT snippets_uc_slug UNIQUE (slug),
+    CONSTRAINT fk_snippets_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE INDEX idx_snippets_expires ON snippets (expires);
+
+CREATE FULLTEXT INDEX idx_snippets_fulltext ON snippets (title, content);
@@ -0,0 +1,19 @@
+CREATE TABLE snippets (
+    id SERIAL PRIMARY KEY,
+    slug CHAR(10) NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    created TIMESTAMP NOT NULL,
+    expires TIMESTAMP,
+    user_id INTEGER NOT NULL REFERENCES users (id),
+    language VARCHAR(20) NOT NULL DEFAULT 'text',
+    visibility VARCHAR(10) NOT NULL DEFAULT 'public',
+    views_left INTEGER,
+    hashed_password BYTEA,
+    encrypted BOOLEAN NOT NULL DEFAULT FALSE,
+    CONSTRAINT snippets_uc_slug UNIQUE (slug)
+);
+
+CREATE INDEX idx_snippets_expires ON snippets (expires);
+
+CREATE INDEX idx_snippets_fulltext ON snippets USING GIN (to_tsvector('simple', title || ' ' || content));
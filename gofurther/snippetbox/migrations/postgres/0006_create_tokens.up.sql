@@ -0,0 +1,7 @@
+CREATE TABLE tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    hash BYTEA NOT NULL UNIQUE,
+    created TIMESTAMP NOT NULL
+);
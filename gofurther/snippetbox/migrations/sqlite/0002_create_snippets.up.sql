@@ -0,0 +1,17 @@
+CREATE TABLE snippets (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    slug TEXT NOT NULL,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME,
+    user_id INTEGER NOT NULL REFERENCES users (id),
+    language TEXT NOT NULL DEFAULT 'text',
+    visibility TEXT NOT NULL DEFAULT 'public',
+    views_left INTEGER,
+    hashed_password BLOB,
+    encrypted BOOLEAN NOT NULL DEFAULT FALSE,
+    CONSTRAINT snippets_uc_slug UNIQUE (slug)
+);
+
+CREATE INDEX idx_snippets_expires ON snippets (expires);
@@ -0,0 +1,108 @@
+// End to end encrypted snippets. Content is encrypted with AES-GCM in the browser and the
+// key only ever lives in the url fragment, which browsers never send to the server.
+// Stored content is "v1:" + base64(iv || ciphertext), the key is the raw 256 bit key in base64url.
+// The version lets the scheme change later, the server rejects content without one.
+
+function toBase64(bytes) {
+	var bin = "";
+	for (var i = 0; i < bytes.length; i++) {
+		bin += String.fromCharCode(bytes[i]);
+	}
+	return btoa(bin);
+}
+
+function fromBase64(str) {
+	var bin = atob(str);
+	var bytes = new Uint8Array(bin.length);
+	for (var i = 0; i < bin.length; i++) {
+		bytes[i] = bin.charCodeAt(i);
+	}
+	return bytes;
+}
+
+function toBase64URL(bytes) {
+	return toBase64(bytes).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+}
+
+function fromBase64URL(str) {
+	str = str.replace(/-/g, "+").replace(/_/g, "/");
+	while (str.length % 4) {
+		str += "=";
+	}
+	return fromBase64(str);
+}
+
+async function encryptContent(plaintext) {
+	var key = await crypto.subtle.generateKey({name: "AES-GCM", length: 256}, true, ["encrypt"]);
+	var iv = crypto.getRandomValues(new Uint8Array(12));
+	var ciphertext = new Uint8Array(await crypto.subtle.encrypt({name: "AES-GCM", iv: iv}, key, new TextEncoder().encode(plaintext)));
+
+	var payload = new Uint8Array(iv.length + ciphertext.length);
+	payload.set(iv);
+	payload.set(ciphertext, iv.length);
+
+	var rawKey = new Uint8Array(await crypto.subtle.exportKey("raw", key));
+	return {content: "v1:" + toBase64(payload), key: toBase64URL(rawKey)};
+}
+
+async function decryptContent(content, encodedKey) {
+	if (!content.startsWith("v1:")) {
+		throw new Error("unknown envelope version");
+	}
+	var payload = fromBase64(content.slice(3));
+	var key = await crypto.subtle.importKey("raw", fromBase64URL(encodedKey), {name: "AES-GCM"}, false, ["decrypt"]);
+	var plaintext = await crypto.subtle.decrypt({name: "AES-GCM", iv: payload.slice(0, 12)}, key, payload.slice(12));
+	return new TextDecoder().decode(plaintext);
+}
+
+// the create form is posted with fetch so we can add the key to the snippet url we get
+// redirected to. listening on the document keeps working after a validation error
+// swaps the form for the one the server rendered
+document.addEventListener("submit", async function (event) {
+	var form = event.target;
+	if (form.id !== "encrypted-form") {
+		return;
+	}
+	event.preventDefault();
+
+	var body = new URLSearchParams(new FormData(form));
+	var encrypted = await encryptContent(body.get("content"));
+	body.set("content", encrypted.content);
+
+	var res = await fetch(form.action, {method: "POST", body: body});
+	if (res.ok && res.redirected) {
+		window.location = res.url + "#" + encrypted.key;
+		return;
+	}
+
+	var page = new DOMParser().parseFromString(await res.text(), "text/html");
+	var main = page.querySelector("main");
+	if (main) {
+		document.querySelector("main").innerHTML = main.innerHTML;
+		// the server only ever saw ciphertext, put back what the user typed
+		document.querySelector("#encrypted-form textarea[name=content]").value = new FormData(form).get("content");
+	}
+});
+
+// forms on the unlock and burn after reading pages post to another url, carry the
+// fragment along so the key is still there once the snippet is shown
+var keepFragment = document.querySelectorAll("form[data-keep-fragment]");
+for (var i = 0; i < keepFragment.length; i++) {
+	keepFragment[i].action += window.location.hash;
+}
+
+var encryptedBlocks = document.querySelectorAll("code[data-ciphertext]");
+for (var i = 0; i < encryptedBlocks.length; i++) {
+	(function (block) {
+		var key = window.location.hash.slice(1);
+		if (!key) {
+			block.textContent = "This snippet is encrypted and the link you followed has no key.";
+			return;
+		}
+		decryptContent(block.dataset.ciphertext, key).then(function (plaintext) {
+			block.textContent = plaintext;
+		}, function () {
+			block.textContent = "This snippet could not be decrypted, the key in the link is wrong.";
+		});
+	})(encryptedBlocks[i]);
+}